@@ -0,0 +1,78 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// encryptedMagic 是加密文件的开头，后面依次是 salt、nonce 和 AES-256-GCM 密文
+var encryptedMagic = []byte("KMAENC1")
+
+const (
+	saltSize = 16
+	// 加密文件的后缀
+	encryptedExt = ".enc"
+)
+
+func deriveKey(passphrase string, salt []byte) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, 32)
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encrypt(data []byte, passphrase string) ([]byte, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte{}, encryptedMagic...)
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, encryptedMagic), nil
+}
+
+func decrypt(data []byte, passphrase string) ([]byte, error) {
+	if !bytes.HasPrefix(data, encryptedMagic) {
+		return nil, fmt.Errorf("不是加密文件")
+	}
+	data = data[len(encryptedMagic):]
+	if len(data) < saltSize {
+		return nil, fmt.Errorf("加密文件不完整")
+	}
+	gcm, err := newGCM(passphrase, data[:saltSize])
+	if err != nil {
+		return nil, err
+	}
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("加密文件不完整")
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], encryptedMagic)
+	if err != nil {
+		return nil, fmt.Errorf("解密失败，密码错误或文件已损坏")
+	}
+	return plain, nil
+}
@@ -0,0 +1,300 @@
+package backup
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// Secret 的导出方式
+const (
+	SecretsInclude = "include"
+	SecretsExclude = "exclude"
+	// 使用 passphrase 加密，导入时需要同样的 passphrase
+	SecretsEncrypt = "encrypt"
+)
+
+// manifestFile 是导出包中的描述文件
+const manifestFile = "export.json"
+
+// skipResources 不导出的资源，它们由控制器或 API Server 生成
+var skipResources = map[string]bool{
+	"events":         true,
+	"endpoints":      true,
+	"endpointslices": true,
+	"leases":         true,
+}
+
+// 不导出的服务端注解
+var skipAnnotations = []string{
+	"kubectl.kubernetes.io/last-applied-configuration",
+	"deployment.kubernetes.io/revision",
+	"pv.kubernetes.io/bind-completed",
+	"pv.kubernetes.io/bound-by-controller",
+	"volume.beta.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/storage-provisioner",
+	"volume.kubernetes.io/selected-node",
+}
+
+// Manifest 描述导出包的内容
+type Manifest struct {
+	Namespace  string `json:"namespace"`
+	ExportTime string `json:"exportTime"`
+	Secrets    string `json:"secrets"`
+	Objects    int    `json:"objects"`
+	// 无法导出的资源类型，一般是没有 list 权限
+	Errors []string `json:"errors,omitempty"`
+}
+
+type ExportNamespaceRequest struct {
+	Namespace string `json:"namespace"`
+	// include、exclude 或 encrypt，默认 exclude
+	Secrets    string `json:"secrets"`
+	Passphrase string `json:"passphrase"`
+}
+
+type ExportNamespaceResponse struct {
+	handlers.ErrorResponse
+}
+
+// ExportNamespace 导出命名空间下的所有资源，返回按类型组织 YAML 的 tar.gz，
+// 例如 Deployment.apps/web.yaml、ConfigMap/app-config.yaml
+func ExportNamespace(w http.ResponseWriter, r *http.Request) {
+	var resp ExportNamespaceResponse
+
+	// 解析请求参数
+	var req ExportNamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		writeJSON(w, resp)
+		return
+	}
+	if req.Secrets == "" {
+		req.Secrets = SecretsExclude
+	}
+	if req.Secrets != SecretsInclude && req.Secrets != SecretsExclude && req.Secrets != SecretsEncrypt {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("无效的 secrets: %s", req.Secrets)
+		writeJSON(w, resp)
+		return
+	}
+	if req.Secrets == SecretsEncrypt && req.Passphrase == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "加密 Secret 需要 passphrase"
+		writeJSON(w, resp)
+		return
+	}
+	if _, err := k8s.GetClient().CoreV1().Namespaces().Get(r.Context(), req.Namespace, metav1.GetOptions{}); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("获取命名空间失败: %v", err)
+		writeJSON(w, resp)
+		return
+	}
+
+	data, err := exportNamespace(r, req)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("导出失败: %v", err)
+		writeJSON(w, resp)
+		return
+	}
+	w.Header().Set("Content-Type", "application/gzip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s-%s.tar.gz", req.Namespace, time.Now().Format("20060102150405")))
+	w.Write(data)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+type archiveFile struct {
+	name string
+	data []byte
+}
+
+func exportNamespace(r *http.Request, req ExportNamespaceRequest) ([]byte, error) {
+	resources, err := handlers.DiscoverResources()
+	if err != nil {
+		return nil, err
+	}
+	manifest := Manifest{
+		Namespace:  req.Namespace,
+		ExportTime: time.Now().Format(time.RFC3339),
+		Secrets:    req.Secrets,
+	}
+
+	var files []archiveFile
+	for _, res := range resources {
+		if !res.Namespaced || skipResources[res.Resource] || !res.HasVerbs("list", "get", "create") {
+			continue
+		}
+		if res.Group == "" && res.Resource == "secrets" && req.Secrets == SecretsExclude {
+			continue
+		}
+		list, err := k8s.GetDynamicClient().Resource(res.GroupVersionResource).Namespace(req.Namespace).List(r.Context(), metav1.ListOptions{})
+		if err != nil {
+			manifest.Errors = append(manifest.Errors, fmt.Sprintf("%s: %v", res.GroupVersionResource.String(), err))
+			continue
+		}
+		for i := range list.Items {
+			obj := &list.Items[i]
+			if IsDerived(obj) {
+				continue
+			}
+			CleanForExport(obj)
+			data, err := k8s.ResourceToYAML(obj.Object)
+			if err != nil {
+				return nil, fmt.Errorf("转换YAML失败: %v", err)
+			}
+			name := path.Join(kindDir(obj), obj.GetName()+".yaml")
+			if obj.GetKind() == "Secret" && req.Secrets == SecretsEncrypt {
+				if data, err = encryptString(data, req.Passphrase); err != nil {
+					return nil, fmt.Errorf("加密 Secret %s 失败: %v", obj.GetName(), err)
+				}
+				name += encryptedExt
+			}
+			files = append(files, archiveFile{name: name, data: []byte(data)})
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].name < files[j].name })
+	manifest.Objects = len(files)
+
+	manifestData, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	files = append([]archiveFile{{name: manifestFile, data: manifestData}}, files...)
+	return writeArchive(files)
+}
+
+func encryptString(data, passphrase string) (string, error) {
+	encrypted, err := encrypt([]byte(data), passphrase)
+	return string(encrypted), err
+}
+
+// kindDir 返回对象在导出包中的目录，内置 core 资源只用 Kind，其它资源带上 group
+func kindDir(obj *unstructured.Unstructured) string {
+	gvk := obj.GroupVersionKind()
+	if gvk.Group == "" {
+		return gvk.Kind
+	}
+	return gvk.Kind + "." + gvk.Group
+}
+
+// IsDerived 判断对象是否由控制器或集群自动创建，这些对象不需要导出或纳入版本管理
+func IsDerived(obj *unstructured.Unstructured) bool {
+	if len(obj.GetOwnerReferences()) > 0 {
+		return true
+	}
+	switch obj.GetKind() {
+	case "ConfigMap":
+		return obj.GetName() == "kube-root-ca.crt"
+	case "ServiceAccount":
+		return obj.GetName() == "default"
+	case "Secret":
+		secretType, _, _ := unstructured.NestedString(obj.Object, "type")
+		return secretType == "kubernetes.io/service-account-token"
+	}
+	return false
+}
+
+// jobControllerLabels 是 Job 控制器自动添加到 selector 和 Pod 模板上的标签
+var jobControllerLabels = []string{
+	"controller-uid",
+	"job-name",
+	"batch.kubernetes.io/controller-uid",
+	"batch.kubernetes.io/job-name",
+}
+
+// CleanForExport 去掉 API Server 生成的字段，使对象可以在其它命名空间或集群重新创建
+func CleanForExport(obj *unstructured.Unstructured) {
+	k8s.CleanObject(obj, k8s.CleanOptions{ManagedFields: true, ResourceVersion: true, Status: true})
+	for _, field := range []string{"uid", "creationTimestamp", "generation", "selfLink", "deletionTimestamp", "deletionGracePeriodSeconds", "ownerReferences"} {
+		unstructured.RemoveNestedField(obj.Object, "metadata", field)
+	}
+	if annotations := obj.GetAnnotations(); annotations != nil {
+		for _, key := range skipAnnotations {
+			delete(annotations, key)
+		}
+		if len(annotations) == 0 {
+			annotations = nil
+		}
+		obj.SetAnnotations(annotations)
+	}
+
+	switch obj.GetKind() {
+	case "Service":
+		// headless Service 的 clusterIP 需要保留 None
+		if clusterIP, _, _ := unstructured.NestedString(obj.Object, "spec", "clusterIP"); clusterIP != "None" {
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIP")
+			unstructured.RemoveNestedField(obj.Object, "spec", "clusterIPs")
+		}
+		// nodePort 由集群分配，保留时在同一集群的其它命名空间创建会端口冲突
+		unstructured.RemoveNestedField(obj.Object, "spec", "healthCheckNodePort")
+		if ports, found, _ := unstructured.NestedSlice(obj.Object, "spec", "ports"); found {
+			for _, port := range ports {
+				if p, ok := port.(map[string]interface{}); ok {
+					delete(p, "nodePort")
+				}
+			}
+			unstructured.SetNestedSlice(obj.Object, ports, "spec", "ports")
+		}
+	case "Job":
+		// selector 和控制器标签由 Job 控制器按 uid 生成，保留时新 Job 无法通过校验
+		unstructured.RemoveNestedField(obj.Object, "spec", "selector")
+		unstructured.RemoveNestedField(obj.Object, "spec", "manualSelector")
+		for _, label := range jobControllerLabels {
+			unstructured.RemoveNestedField(obj.Object, "metadata", "labels", label)
+			unstructured.RemoveNestedField(obj.Object, "spec", "template", "metadata", "labels", label)
+		}
+	case "Pod":
+		// 目标集群不一定有同名节点，交给调度器重新调度
+		unstructured.RemoveNestedField(obj.Object, "spec", "nodeName")
+	case "PersistentVolumeClaim":
+		unstructured.RemoveNestedField(obj.Object, "spec", "volumeName")
+	case "ServiceAccount":
+		unstructured.RemoveNestedField(obj.Object, "secrets")
+	}
+}
+
+func writeArchive(files []archiveFile) ([]byte, error) {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	now := time.Now()
+	for _, f := range files {
+		header := &tar.Header{
+			Name:    strings.TrimPrefix(f.name, "/"),
+			Mode:    0o644,
+			Size:    int64(len(f.data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return nil, err
+		}
+		if _, err := tw.Write(f.data); err != nil {
+			return nil, err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
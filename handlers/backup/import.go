@@ -0,0 +1,281 @@
+package backup
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"path"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// 导入包的大小限制
+const (
+	maxArchiveSize = 64 << 20
+	maxFileSize    = 8 << 20
+)
+
+// 对象已存在时的处理方式
+const (
+	PolicySkipExisting = "skip-existing"
+	// 服务端 apply 并强制接管冲突字段
+	PolicyOverwrite = "overwrite"
+)
+
+type ImportOptions struct {
+	// 原命名空间到新命名空间的映射，导出包的命名空间可以用 manifest 中的 namespace 作为 key
+	NamespaceMapping map[string]string `json:"namespaceMapping"`
+	// 目标命名空间不存在时创建
+	CreateNamespace   bool              `json:"createNamespace"`
+	SetLabels         map[string]string `json:"setLabels"`
+	RemoveLabels      []string          `json:"removeLabels"`
+	SetAnnotations    map[string]string `json:"setAnnotations"`
+	RemoveAnnotations []string          `json:"removeAnnotations"`
+	// skip-existing 或 overwrite，默认 skip-existing
+	Policy string `json:"policy"`
+	// 解密导出时加密的 Secret
+	Passphrase string `json:"passphrase"`
+	DryRun     bool   `json:"dryRun"`
+}
+
+type ImportNamespaceResponse struct {
+	handlers.ErrorResponse
+	Manifest *Manifest         `json:"manifest,omitempty"`
+	Results  []k8s.ApplyResult `json:"results,omitempty"`
+	// 无法读取、解密或解析的文件
+	FileErrors []string `json:"fileErrors,omitempty"`
+}
+
+// ImportNamespace 导入 ExportNamespace 生成的 tar.gz，也支持任意包含 YAML 文件的 tar.gz。
+// multipart 表单中 file 为导入包，options 为 ImportOptions 的 JSON。
+// 对象按依赖关系排序后通过服务端 apply 创建，返回每个对象的结果
+func ImportNamespace(w http.ResponseWriter, r *http.Request) {
+	var resp ImportNamespaceResponse
+	defer func() {
+		writeJSON(w, resp)
+	}()
+
+	// 解析请求参数
+	r.Body = http.MaxBytesReader(w, r.Body, maxArchiveSize+1<<20)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("读取导入包失败: %v", err)
+		return
+	}
+	defer file.Close()
+	var opt ImportOptions
+	if options := r.FormValue("options"); options != "" {
+		if err := json.Unmarshal([]byte(options), &opt); err != nil {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = fmt.Sprintf("解析 options 失败: %v", err)
+			return
+		}
+	}
+	if opt.Policy == "" {
+		opt.Policy = PolicySkipExisting
+	}
+	if opt.Policy != PolicySkipExisting && opt.Policy != PolicyOverwrite {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("无效的 policy: %s", opt.Policy)
+		return
+	}
+
+	files, manifest, err := readArchive(file)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("读取导入包失败: %v", err)
+		return
+	}
+	resp.Manifest = manifest
+
+	var docs []string
+	namespaces := make(map[string]bool)
+	for _, f := range files {
+		objs, err := decodeFile(f, opt.Passphrase)
+		if err != nil {
+			resp.FileErrors = append(resp.FileErrors, fmt.Sprintf("%s: %v", f.name, err))
+			continue
+		}
+		for _, obj := range objs {
+			rewriteObject(obj, opt)
+			if ns := obj.GetNamespace(); ns != "" {
+				namespaces[ns] = true
+			}
+			data, err := k8s.ResourceToYAML(obj.Object)
+			if err != nil {
+				resp.FileErrors = append(resp.FileErrors, fmt.Sprintf("%s: 转换YAML失败: %v", f.name, err))
+				continue
+			}
+			docs = append(docs, data)
+		}
+	}
+	if opt.CreateNamespace {
+		for ns := range namespaces {
+			_, err := k8s.GetClient().CoreV1().Namespaces().Get(r.Context(), ns, metav1.GetOptions{})
+			if errors.IsNotFound(err) {
+				docs = append(docs, fmt.Sprintf("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: %s\n", ns))
+			}
+		}
+	}
+	if len(docs) == 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "导入包中没有可导入的对象"
+		return
+	}
+
+	client := k8s.YamlOperationClient
+	if opt.DryRun {
+		client = k8s.YamlOperationDryRunClient
+	}
+	resp.Results, err = client.ApplyAll(strings.Join(docs, "---\n"), k8s.ApplyOptions{
+		FieldManager: handlers.FieldManager(r, ""),
+		Force:        opt.Policy == PolicyOverwrite,
+		SkipExisting: opt.Policy == PolicySkipExisting,
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("导入失败: %v", err)
+		return
+	}
+	failed := len(resp.FileErrors)
+	for _, result := range resp.Results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("%d 个对象或文件导入失败", failed)
+	}
+}
+
+// readArchive 读取 tar.gz 中的 YAML 文件和 export.json
+func readArchive(r io.Reader) ([]archiveFile, *Manifest, error) {
+	gz, err := gzip.NewReader(io.LimitReader(r, maxArchiveSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gz.Close()
+
+	var (
+		files    []archiveFile
+		manifest *Manifest
+		total    int64
+	)
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if header.Size > maxFileSize {
+			return nil, nil, fmt.Errorf("%s 超过 %d MB", header.Name, maxFileSize>>20)
+		}
+		total += header.Size
+		if total > maxArchiveSize {
+			return nil, nil, fmt.Errorf("解压后超过 %d MB", maxArchiveSize>>20)
+		}
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		name := path.Clean(header.Name)
+		switch {
+		case path.Base(name) == manifestFile:
+			manifest = &Manifest{}
+			if err := json.Unmarshal(data, manifest); err != nil {
+				return nil, nil, fmt.Errorf("解析 %s 失败: %v", manifestFile, err)
+			}
+		case isYamlFile(strings.TrimSuffix(name, encryptedExt)):
+			files = append(files, archiveFile{name: name, data: data})
+		}
+	}
+	return files, manifest, nil
+}
+
+func isYamlFile(name string) bool {
+	return strings.HasSuffix(name, ".yaml") || strings.HasSuffix(name, ".yml")
+}
+
+// decodeFile 解密并解析文件中的对象，文件可以包含多个文档
+func decodeFile(f archiveFile, passphrase string) ([]*unstructured.Unstructured, error) {
+	data := f.data
+	if strings.HasSuffix(f.name, encryptedExt) {
+		if passphrase == "" {
+			return nil, fmt.Errorf("文件已加密，需要 passphrase")
+		}
+		var err error
+		if data, err = decrypt(data, passphrase); err != nil {
+			return nil, err
+		}
+	}
+	docs, err := k8s.YamlOperationDryRunClient.Documents(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var objs []*unstructured.Unstructured
+	for _, doc := range docs {
+		if doc.Object == nil {
+			return nil, doc.Err
+		}
+		// 映射失败（例如 CRD 还没有创建）的对象交给 apply 时再处理
+		objs = append(objs, doc.Object)
+	}
+	return objs, nil
+}
+
+// rewriteObject 按选项修改命名空间、标签和注解
+func rewriteObject(obj *unstructured.Unstructured, opt ImportOptions) {
+	if ns, ok := opt.NamespaceMapping[obj.GetNamespace()]; ok && obj.GetNamespace() != "" {
+		obj.SetNamespace(ns)
+	}
+	if obj.GetKind() == "RoleBinding" || obj.GetKind() == "ClusterRoleBinding" {
+		subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+		for _, item := range subjects {
+			s, ok := item.(map[string]interface{})
+			if !ok || s["kind"] != "ServiceAccount" {
+				continue
+			}
+			if ns, ok := opt.NamespaceMapping[fmt.Sprint(s["namespace"])]; ok {
+				s["namespace"] = ns
+			}
+		}
+		if subjects != nil {
+			unstructured.SetNestedSlice(obj.Object, subjects, "subjects")
+		}
+	}
+	obj.SetLabels(rewriteMap(obj.GetLabels(), opt.SetLabels, opt.RemoveLabels))
+	obj.SetAnnotations(rewriteMap(obj.GetAnnotations(), opt.SetAnnotations, opt.RemoveAnnotations))
+}
+
+func rewriteMap(m map[string]string, set map[string]string, remove []string) map[string]string {
+	for _, key := range remove {
+		delete(m, key)
+	}
+	if len(set) > 0 && m == nil {
+		m = make(map[string]string, len(set))
+	}
+	for k, v := range set {
+		m[k] = v
+	}
+	if len(m) == 0 {
+		return nil
+	}
+	return m
+}
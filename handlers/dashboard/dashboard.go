@@ -2,8 +2,10 @@ package dashboard
 
 import (
 	"encoding/json"
+	"k8s-manage-api/handlers/event"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -22,6 +24,7 @@ type ClusterResourceStats struct {
 	LBServices         int `json:"lbServices"`            // 负载均衡服务数
 	Ingresses          int `json:"ingresses"`             // Ingress数
 	PVCs               int `json:"pvcs"`                  // 持久卷声明数
+	RecentWarnings     []event.Warning `json:"recentWarnings"` // 最近一小时的告警事件
 }
 
 // GetClusterResourceStats 获取集群资源统计信息
@@ -99,6 +102,11 @@ func GetClusterResourceStats(w http.ResponseWriter, r *http.Request) {
 		stats.PVCs = len(pvcs.Items)
 	}
 
+	// 获取最近一小时出现最多的告警事件
+	if warnings, err := event.TopWarnings(ctx, "", time.Hour, 10); err == nil {
+		stats.RecentWarnings = warnings
+	}
+
 // 返回 JSON 响应
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
@@ -0,0 +1,304 @@
+package drift
+
+import (
+	"context"
+	"fmt"
+	"k8s-manage-api/k8s"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+)
+
+// fieldManager 是漂移检测和重新同步使用的 field manager。
+// 重新同步后 Git 中删除的字段会在下一次同步时被删除
+const fieldManager = k8s.DefaultFieldManager + "-gitops"
+
+// 默认检测间隔
+const defaultInterval = 5 * time.Minute
+
+// 对象的漂移状态
+const (
+	StatusInSync  = "InSync"
+	StatusDrifted = "Drifted"
+	// Git 中有，集群中没有
+	StatusMissing = "Missing"
+	// 集群中有，Git 中没有
+	StatusExtra = "Extra"
+	StatusError = "Error"
+)
+
+// Target 把一个本地清单目录（例如 git 仓库的工作区）映射到集群的命名空间
+type Target struct {
+	Name string `json:"name"`
+	// 清单根目录下的目录，见 manifestRoot
+	Path string `json:"path"`
+	// 不为空时覆盖所有命名空间级对象的命名空间
+	Namespace string `json:"namespace"`
+	// 检测间隔，单位秒，默认 300
+	IntervalSeconds int `json:"intervalSeconds"`
+	// 清单中的集群级类型（例如 Namespace、ClusterRole）也检测多余对象，并在 deleteExtra 时删除
+	PruneClusterScoped bool `json:"pruneClusterScoped"`
+}
+
+// applySet 返回目标同步时给对象打上的 apply set 标签值，只有带这个标签的对象才属于该目标
+func (t Target) applySet() string {
+	return "gitops-" + t.Name
+}
+
+// applyOptions 返回同步和检测共用的 apply 参数，检测多余对象和 prune 使用同样的查找范围
+func (t Target) applyOptions(prune bool) k8s.ApplyOptions {
+	return k8s.ApplyOptions{
+		FieldManager:       fieldManager,
+		Force:              true,
+		ApplySet:           t.applySet(),
+		Prune:              prune,
+		PruneClusterScoped: t.PruneClusterScoped,
+	}
+}
+
+func (t Target) interval() time.Duration {
+	if t.IntervalSeconds <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(t.IntervalSeconds) * time.Second
+}
+
+type Report struct {
+	Target    string         `json:"target"`
+	CheckTime string         `json:"checkTime"`
+	Error     string         `json:"error,omitempty"`
+	Summary   map[string]int `json:"summary"`
+	Objects   []DriftObject  `json:"objects"`
+}
+
+type DriftObject struct {
+	File       string `json:"file,omitempty"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Status     string `json:"status"`
+	// 线上对象和按清单 apply 后的 unified diff
+	Diff  string `json:"diff,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+var (
+	reportsMu sync.RWMutex
+	reports   = make(map[string]*Report)
+	lastCheck = make(map[string]time.Time)
+)
+
+func latestReport(name string) *Report {
+	reportsMu.RLock()
+	defer reportsMu.RUnlock()
+	return reports[name]
+}
+
+// run 每分钟检查一次哪些目标到了检测时间
+func run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		targets, err := listTargets()
+		if err != nil {
+			log.Printf("读取漂移检测目标失败: %v", err)
+			continue
+		}
+		for _, t := range targets {
+			reportsMu.RLock()
+			due := time.Since(lastCheck[t.Name]) >= t.interval()
+			reportsMu.RUnlock()
+			if due {
+				Check(context.Background(), t)
+			}
+		}
+	}
+}
+
+// manifestFile 是清单目录中的一个文件，命名空间已经按目标覆盖
+type manifestFile struct {
+	name string
+	yaml string
+}
+
+// loadManifests 读取目录下所有 YAML 和 JSON 文件，跳过隐藏目录（例如 .git）
+func loadManifests(t Target) ([]manifestFile, error) {
+	var files []manifestFile
+	root, err := manifestDir(t.Path)
+	if err != nil {
+		return nil, err
+	}
+	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != root && strings.HasPrefix(d.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(root, path)
+		docs, err := k8s.YamlOperationDryRunClient.Documents(string(data))
+		if err != nil {
+			return fmt.Errorf("%s: %v", rel, err)
+		}
+		var out []string
+		for _, doc := range docs {
+			if doc.Object == nil {
+				return fmt.Errorf("%s: %v", rel, doc.Err)
+			}
+			if t.Namespace != "" && doc.Mapping != nil && doc.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+				doc.Object.SetNamespace(t.Namespace)
+			}
+			yamlData, err := k8s.ResourceToYAML(doc.Object.Object)
+			if err != nil {
+				return fmt.Errorf("%s: %v", rel, err)
+			}
+			out = append(out, yamlData)
+		}
+		if len(out) > 0 {
+			files = append(files, manifestFile{name: rel, yaml: strings.Join(out, "---\n")})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("读取清单目录失败: %v", err)
+	}
+	return files, nil
+}
+
+// Check 对比清单和线上对象，结果保存为目标的最新报告。
+// 通过服务端 apply dry-run 计算清单 apply 后的对象，默认值和服务端维护的字段不会被当作漂移
+func Check(ctx context.Context, t Target) *Report {
+	report := &Report{Target: t.Name, CheckTime: time.Now().Format("2006-01-02 15:04:05"), Summary: make(map[string]int)}
+	defer func() {
+		reportsMu.Lock()
+		reports[t.Name] = report
+		lastCheck[t.Name] = time.Now()
+		reportsMu.Unlock()
+	}()
+
+	files, err := loadManifests(t)
+	if err != nil {
+		report.Error = err.Error()
+		return report
+	}
+	opt := t.applyOptions(false)
+	for _, f := range files {
+		results, err := k8s.YamlOperationDryRunClient.Preview(f.yaml, opt)
+		if err != nil {
+			report.Objects = append(report.Objects, DriftObject{File: f.name, Status: StatusError, Error: err.Error()})
+			continue
+		}
+		for _, result := range results {
+			obj := DriftObject{
+				File:       f.name,
+				APIVersion: result.APIVersion,
+				Kind:       result.Kind,
+				Namespace:  result.Namespace,
+				Name:       result.Name,
+				Diff:       result.Diff,
+			}
+			switch {
+			case result.Failed():
+				obj.Status = StatusError
+				obj.Error = result.Error
+				if len(result.ValidationErrors) > 0 {
+					obj.Error = strings.Join(result.ValidationErrors, "; ")
+				}
+			case result.Action == k8s.ActionCreated:
+				obj.Status = StatusMissing
+			case result.Diff == "":
+				obj.Status = StatusInSync
+			default:
+				obj.Status = StatusDrifted
+			}
+			report.Objects = append(report.Objects, obj)
+		}
+	}
+
+	extras, err := findExtras(t, files)
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.Objects = append(report.Objects, extras...)
+	for _, obj := range report.Objects {
+		report.Summary[obj.Status]++
+	}
+	return report
+}
+
+// findExtras 查找由该目标同步过（带有目标的 apply set 标签）、但清单里已经没有的对象。
+// 和 Sync 的 prune 使用同一个查找范围，报告中的多余对象就是 deleteExtra 时会被删除的对象
+func findExtras(t Target, files []manifestFile) ([]DriftObject, error) {
+	docs, err := k8s.YamlOperationDryRunClient.Documents(joinManifests(files))
+	if err != nil {
+		return nil, err
+	}
+	candidates, skipped, err := k8s.YamlOperationDryRunClient.PruneCandidates(docs, t.applyOptions(true))
+	if err != nil {
+		return nil, err
+	}
+
+	var extras []DriftObject
+	for i := range candidates {
+		obj := &candidates[i].Object
+		diff, _ := k8s.ObjectDiff(obj, nil)
+		extras = append(extras, DriftObject{
+			APIVersion: obj.GetAPIVersion(),
+			Kind:       obj.GetKind(),
+			Namespace:  obj.GetNamespace(),
+			Name:       obj.GetName(),
+			Status:     StatusExtra,
+			Diff:       diff,
+		})
+	}
+	sort.Slice(extras, func(i, j int) bool {
+		if extras[i].Kind != extras[j].Kind {
+			return extras[i].Kind < extras[j].Kind
+		}
+		return extras[i].Name < extras[j].Name
+	})
+	for _, s := range skipped {
+		extras = append(extras, DriftObject{APIVersion: s.APIVersion, Kind: s.Kind, Status: StatusError, Error: s.Error})
+	}
+	return extras, nil
+}
+
+func joinManifests(files []manifestFile) string {
+	var docs []string
+	for _, f := range files {
+		docs = append(docs, f.yaml)
+	}
+	return strings.Join(docs, "---\n")
+}
+
+// Sync 按清单重新 apply，强制接管被手动修改的字段，并给对象打上目标的 apply set 标签。
+// deleteExtra 为 true 时通过 prune 删除之前同步过、但清单中已经没有的对象，即 Check 报告为 Extra 的对象，其它团队、Helm 或 operator 创建的对象不受影响
+func Sync(t Target, dryRun, deleteExtra bool) ([]k8s.ApplyResult, error) {
+	files, err := loadManifests(t)
+	if err != nil {
+		return nil, err
+	}
+	client := k8s.YamlOperationClient
+	if dryRun {
+		client = k8s.YamlOperationDryRunClient
+	}
+	return client.ApplyAll(joinManifests(files), t.applyOptions(deleteExtra))
+}
@@ -0,0 +1,228 @@
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"k8s-manage-api/store"
+	"net/http"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+type ListTargetResponse struct {
+	handlers.ErrorResponse
+	Targets []Target `json:"targets"`
+}
+
+func ListTarget(w http.ResponseWriter, r *http.Request) {
+	var resp ListTargetResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	targets, err := listTargets()
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取目标列表失败: %v", err)
+		return
+	}
+	resp.Targets = targets
+}
+
+type SaveTargetResponse struct {
+	handlers.ErrorResponse
+}
+
+// SaveTarget 创建或修改漂移检测目标，保存后立即检测一次
+func SaveTarget(w http.ResponseWriter, r *http.Request) {
+	var resp SaveTargetResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var t Target
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if t.Name == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "name 不能为空"
+		return
+	}
+	// 名称用作 apply set 标签的值
+	if errs := validation.IsValidLabelValue(t.applySet()); len(errs) > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("无效的名称 %s: %s", t.Name, strings.Join(errs, "; "))
+		return
+	}
+	if _, err := manifestDir(t.Path); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	if t.Namespace != "" {
+		if errs := validation.IsDNS1123Label(t.Namespace); len(errs) > 0 {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = fmt.Sprintf("无效的命名空间 %s", t.Namespace)
+			return
+		}
+	}
+	if err := store.Put(targetBucket, t.Name, t); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("保存目标失败: %v", err)
+		return
+	}
+	go Check(context.Background(), t)
+}
+
+type TargetNameRequest struct {
+	Name string `json:"name"`
+}
+
+type DeleteTargetResponse struct {
+	handlers.ErrorResponse
+}
+
+func DeleteTarget(w http.ResponseWriter, r *http.Request) {
+	var resp DeleteTargetResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req TargetNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := store.Delete(targetBucket, req.Name); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("删除目标失败: %v", err)
+		return
+	}
+	reportsMu.Lock()
+	delete(reports, req.Name)
+	delete(lastCheck, req.Name)
+	reportsMu.Unlock()
+}
+
+type GetDriftRequest struct {
+	// 为空时返回所有目标的最新报告
+	Name string `json:"name"`
+	// 立即重新检测，否则返回最近一次定时检测的结果
+	Refresh bool `json:"refresh"`
+}
+
+type GetDriftResponse struct {
+	handlers.ErrorResponse
+	Reports []*Report `json:"reports"`
+}
+
+// GetDrift 返回漂移检测报告，包括漂移、缺失和多余的对象以及 diff
+func GetDrift(w http.ResponseWriter, r *http.Request) {
+	var resp GetDriftResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req GetDriftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	var targets []Target
+	if req.Name != "" {
+		t, err := getTarget(req.Name)
+		if err != nil {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = err.Error()
+			return
+		}
+		targets = append(targets, t)
+	} else {
+		var err error
+		if targets, err = listTargets(); err != nil {
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = fmt.Sprintf("获取目标列表失败: %v", err)
+			return
+		}
+	}
+	for _, t := range targets {
+		report := latestReport(t.Name)
+		if req.Refresh || report == nil {
+			report = Check(r.Context(), t)
+		}
+		resp.Reports = append(resp.Reports, report)
+	}
+}
+
+type SyncDriftRequest struct {
+	Name   string `json:"name"`
+	DryRun bool   `json:"dryRun"`
+	// 同时删除之前由该目标同步过、但清单中已经没有的对象
+	DeleteExtra bool `json:"deleteExtra"`
+}
+
+type SyncDriftResponse struct {
+	handlers.ErrorResponse
+	Results []k8s.ApplyResult `json:"results,omitempty"`
+	Report  *Report           `json:"report,omitempty"`
+}
+
+// SyncDrift 按清单重新同步目标，完成后重新检测并返回新的报告
+func SyncDrift(w http.ResponseWriter, r *http.Request) {
+	var resp SyncDriftResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req SyncDriftRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	t, err := getTarget(req.Name)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	resp.Results, err = Sync(t, req.DryRun, req.DeleteExtra)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("同步失败: %v", err)
+		return
+	}
+	failed := 0
+	for _, result := range resp.Results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("%d 个对象同步失败", failed)
+	}
+	if !req.DryRun {
+		resp.Report = Check(r.Context(), t)
+	}
+}
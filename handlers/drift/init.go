@@ -0,0 +1,5 @@
+package drift
+
+func init() {
+	go run()
+}
@@ -0,0 +1,80 @@
+package drift
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/store"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// targetBucket 是本地存储中保存漂移检测目标的 bucket
+const targetBucket = "drift-targets"
+
+// manifestRoot 返回清单目录的根目录，目标的 path 只能是它下面的目录。
+// 由环境变量 K8S_MANAGE_API_MANIFEST_DIR 指定，默认为 data/manifests
+func manifestRoot() string {
+	if path := os.Getenv("K8S_MANAGE_API_MANIFEST_DIR"); path != "" {
+		return path
+	}
+	return filepath.Join("data", "manifests")
+}
+
+// manifestDir 把目标的 path 解析为清单根目录下的真实目录，path 可以是相对根目录的路径，
+// 通过 .. 或符号链接跳出根目录时返回错误
+func manifestDir(path string) (string, error) {
+	root, err := filepath.EvalSymlinks(manifestRoot())
+	if err != nil {
+		return "", fmt.Errorf("清单根目录 %s 不存在", manifestRoot())
+	}
+	root, err = filepath.Abs(root)
+	if err != nil {
+		return "", err
+	}
+	dir := path
+	if !filepath.IsAbs(dir) {
+		dir = filepath.Join(root, dir)
+	}
+	dir, err = filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", fmt.Errorf("清单目录 %s 不存在", path)
+	}
+	dir, err = filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(root, dir)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("清单目录 %s 不在 %s 下", path, manifestRoot())
+	}
+	if info, err := os.Stat(dir); err != nil || !info.IsDir() {
+		return "", fmt.Errorf("清单目录 %s 不存在", path)
+	}
+	return dir, nil
+}
+
+func listTargets() ([]Target, error) {
+	var targets []Target
+	err := store.List(targetBucket, func(key string, data []byte) error {
+		var t Target
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("解析目标 %s 失败: %v", key, err)
+		}
+		targets = append(targets, t)
+		return nil
+	})
+	return targets, err
+}
+
+func getTarget(name string) (Target, error) {
+	var t Target
+	found, err := store.Get(targetBucket, name, &t)
+	if err != nil {
+		return t, fmt.Errorf("读取目标失败: %v", err)
+	}
+	if !found {
+		return t, fmt.Errorf("目标 %s 不存在", name)
+	}
+	return t, nil
+}
@@ -0,0 +1,334 @@
+package event
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/watch"
+)
+
+type ListEventRequest struct {
+	NameSpace string `json:"namespace"`
+	// 关联对象的类型和名称，例如 Pod/nginx-xxx
+	InvolvedKind string `json:"involvedKind"`
+	InvolvedName string `json:"involvedName"`
+	// Normal 或 Warning
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+	// 返回的最大条数，默认 500
+	Limit int `json:"limit"`
+}
+
+type ListEventResponse struct {
+	handlers.ErrorResponse
+	Events []Event `json:"events"`
+}
+
+type Event struct {
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace"`
+	Type         string `json:"type"`
+	Reason       string `json:"reason"`
+	Message      string `json:"message"`
+	InvolvedKind string `json:"involvedKind"`
+	InvolvedName string `json:"involvedName"`
+	Source       string `json:"source"`
+	Count        int32  `json:"count"`
+	FirstTime    string `json:"firstTime"`
+	LastTime     string `json:"lastTime"`
+}
+
+// ListEvent 按命名空间、关联对象、类型和原因查询事件，按最后发生时间倒序返回
+func ListEvent(w http.ResponseWriter, r *http.Request) {
+	var resp ListEventResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ListEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.Limit <= 0 {
+		req.Limit = 500
+	}
+
+	clientset := k8s.GetClient()
+	events, err := clientset.CoreV1().Events(req.NameSpace).List(r.Context(), metav1.ListOptions{
+		FieldSelector: fieldSelector(req.InvolvedKind, req.InvolvedName, req.Type, req.Reason),
+	})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取事件列表失败: %v", err)
+		return
+	}
+
+	items := events.Items
+	sort.Slice(items, func(i, j int) bool {
+		return EventTime(&items[j]).Before(EventTime(&items[i]))
+	})
+	if len(items) > req.Limit {
+		items = items[:req.Limit]
+	}
+	for i := range items {
+		resp.Events = append(resp.Events, toEvent(&items[i]))
+	}
+}
+
+// fieldSelector 构建 core/v1 Event 支持的字段选择器
+func fieldSelector(kind, name, eventType, reason string) string {
+	var selectors []string
+	if kind != "" {
+		selectors = append(selectors, "involvedObject.kind="+kind)
+	}
+	if name != "" {
+		selectors = append(selectors, "involvedObject.name="+name)
+	}
+	if eventType != "" {
+		selectors = append(selectors, "type="+eventType)
+	}
+	if reason != "" {
+		selectors = append(selectors, "reason="+reason)
+	}
+	return strings.Join(selectors, ",")
+}
+
+func toEvent(e *corev1.Event) Event {
+	first := e.FirstTimestamp.Time
+	if first.IsZero() {
+		first = EventTime(e)
+	}
+	count := e.Count
+	if e.Series != nil {
+		count = e.Series.Count
+	}
+	if count == 0 {
+		count = 1
+	}
+	source := e.Source.Component
+	if source == "" {
+		source = e.ReportingController
+	}
+	return Event{
+		Name:         e.Name,
+		Namespace:    e.Namespace,
+		Type:         e.Type,
+		Reason:       e.Reason,
+		Message:      e.Message,
+		InvolvedKind: e.InvolvedObject.Kind,
+		InvolvedName: e.InvolvedObject.Name,
+		Source:       source,
+		Count:        count,
+		FirstTime:    first.Format("2006-01-02 15:04:05"),
+		LastTime:     EventTime(e).Format("2006-01-02 15:04:05"),
+	}
+}
+
+// EventTime 返回事件最后一次发生的时间，events.k8s.io 写入的事件只填写 eventTime 和 series
+func EventTime(e *corev1.Event) time.Time {
+	switch {
+	case e.Series != nil && !e.Series.LastObservedTime.IsZero():
+		return e.Series.LastObservedTime.Time
+	case !e.LastTimestamp.IsZero():
+		return e.LastTimestamp.Time
+	case !e.EventTime.IsZero():
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// StreamEvent 以 Server-Sent Events 的方式推送事件，过滤参数通过 query 传递：
+// namespace、involvedKind、involvedName、type、reason
+func StreamEvent(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "不支持流式响应", http.StatusInternalServerError)
+		return
+	}
+	params := r.URL.Query()
+	namespace := params.Get("namespace")
+	if namespace == "all+" {
+		namespace = ""
+	}
+
+	clientset := k8s.GetClient()
+	selector := fieldSelector(params.Get("involvedKind"), params.Get("involvedName"), params.Get("type"), params.Get("reason"))
+	// 先 List 获取当前的 resourceVersion，再从这个版本开始 Watch，避免新连接先收到所有已有事件的 ADDED
+	list, err := clientset.CoreV1().Events(namespace).List(r.Context(), metav1.ListOptions{FieldSelector: selector, Limit: 1})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("获取事件列表失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	watcher, err := clientset.CoreV1().Events(namespace).Watch(r.Context(), metav1.ListOptions{
+		FieldSelector:   selector,
+		ResourceVersion: list.ResourceVersion,
+	})
+	if err != nil {
+		http.Error(w, fmt.Sprintf("监听事件失败: %v", err), http.StatusInternalServerError)
+		return
+	}
+	defer watcher.Stop()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+			flusher.Flush()
+		case ev, ok := <-watcher.ResultChan():
+			if !ok {
+				return
+			}
+			if ev.Type != watch.Added && ev.Type != watch.Modified {
+				continue
+			}
+			e, ok := ev.Object.(*corev1.Event)
+			if !ok {
+				continue
+			}
+			data, err := json.Marshal(toEvent(e))
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(w, "data: %s\n\n", data)
+			flusher.Flush()
+		}
+	}
+}
+
+type ListWarningRequest struct {
+	NameSpace string `json:"namespace"`
+	// 统计的时间范围，默认 60 分钟
+	Minutes int `json:"minutes"`
+	Limit   int `json:"limit"`
+}
+
+type ListWarningResponse struct {
+	handlers.ErrorResponse
+	Warnings []Warning `json:"warnings"`
+}
+
+type Warning struct {
+	Reason       string `json:"reason"`
+	InvolvedKind string `json:"involvedKind"`
+	// 同一原因和类型下所有事件的累计次数
+	Count int32 `json:"count"`
+	// 受影响的对象，格式为 namespace/name
+	Objects     []string `json:"objects"`
+	LastMessage string   `json:"lastMessage"`
+	LastTime    string   `json:"lastTime"`
+}
+
+// ListWarning 统计最近一段时间的 Warning 事件，按原因和对象类型分组，按次数倒序
+func ListWarning(w http.ResponseWriter, r *http.Request) {
+	var resp ListWarningResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ListWarningRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.Minutes <= 0 {
+		req.Minutes = 60
+	}
+	if req.Limit <= 0 {
+		req.Limit = 20
+	}
+
+	warnings, err := TopWarnings(r.Context(), req.NameSpace, time.Duration(req.Minutes)*time.Minute, req.Limit)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取事件列表失败: %v", err)
+		return
+	}
+	resp.Warnings = warnings
+}
+
+// TopWarnings 返回 since 时间内出现最多的 Warning 事件分组
+func TopWarnings(ctx context.Context, namespace string, since time.Duration, limit int) ([]Warning, error) {
+	clientset := k8s.GetClient()
+	events, err := clientset.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fieldSelector("", "", corev1.EventTypeWarning, ""),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	type group struct {
+		warning  Warning
+		objects  map[string]bool
+		lastTime time.Time
+	}
+	groups := make(map[string]*group)
+	after := time.Now().Add(-since)
+	for i := range events.Items {
+		e := &events.Items[i]
+		last := EventTime(e)
+		if last.Before(after) {
+			continue
+		}
+		key := e.Reason + "/" + e.InvolvedObject.Kind
+		g, ok := groups[key]
+		if !ok {
+			g = &group{
+				warning: Warning{Reason: e.Reason, InvolvedKind: e.InvolvedObject.Kind},
+				objects: make(map[string]bool),
+			}
+			groups[key] = g
+		}
+		g.warning.Count += toEvent(e).Count
+		g.objects[e.InvolvedObject.Namespace+"/"+e.InvolvedObject.Name] = true
+		if last.After(g.lastTime) {
+			g.lastTime = last
+			g.warning.LastMessage = e.Message
+		}
+	}
+
+	var warnings []Warning
+	for _, g := range groups {
+		for obj := range g.objects {
+			g.warning.Objects = append(g.warning.Objects, obj)
+		}
+		sort.Strings(g.warning.Objects)
+		g.warning.LastTime = g.lastTime.Format("2006-01-02 15:04:05")
+		warnings = append(warnings, g.warning)
+	}
+	sort.Slice(warnings, func(i, j int) bool {
+		if warnings[i].Count != warnings[j].Count {
+			return warnings[i].Count > warnings[j].Count
+		}
+		return warnings[i].LastTime > warnings[j].LastTime
+	})
+	if len(warnings) > limit {
+		warnings = warnings[:limit]
+	}
+	return warnings, nil
+}
@@ -0,0 +1,316 @@
+package history
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"k8s-manage-api/store"
+	"net/http"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+type GetConfigResponse struct {
+	handlers.ErrorResponse
+	Config Config `json:"config"`
+}
+
+func GetConfig(w http.ResponseWriter, r *http.Request) {
+	var resp GetConfigResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	c, err := getConfig()
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.Config = c
+}
+
+type SaveConfigResponse struct {
+	handlers.ErrorResponse
+}
+
+func SaveConfig(w http.ResponseWriter, r *http.Request) {
+	var resp SaveConfigResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var c Config
+	if err := json.NewDecoder(r.Body).Decode(&c); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	for _, res := range c.Resources {
+		if res.Version == "" || res.Resource == "" {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = "resources 中的 version 和 resource 不能为空"
+			return
+		}
+	}
+	if err := store.Put(configBucket, configKey, c); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("保存快照配置失败: %v", err)
+		return
+	}
+}
+
+type TakeSnapshotResponse struct {
+	handlers.ErrorResponse
+}
+
+// TakeSnapshot 立即记录一次快照，例如在高风险变更之前
+func TakeSnapshot(w http.ResponseWriter, r *http.Request) {
+	var resp TakeSnapshotResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	c, err := getConfig()
+	if err == nil {
+		err = Snapshot(r.Context(), c)
+	}
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("记录快照失败: %v", err)
+		return
+	}
+}
+
+type GetObjectRequest struct {
+	Group     string `json:"group"`
+	Resource  string `json:"resource"`
+	NameSpace string `json:"namespace"`
+	Name      string `json:"name"`
+	// RFC3339 格式，为空时返回最新记录
+	Time string `json:"time"`
+}
+
+type GetObjectResponse struct {
+	handlers.ErrorResponse
+	// 返回的版本是在这个时间记录的
+	SnapshotTime string `json:"snapshotTime"`
+	Deleted      bool   `json:"deleted"`
+	Yaml         string `json:"yaml"`
+	// 对象所有变化的时间
+	Versions []string `json:"versions"`
+}
+
+// GetObject 返回对象在某个时间点的 YAML
+func GetObject(w http.ResponseWriter, r *http.Request) {
+	var resp GetObjectResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req GetObjectRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	t, err := parseTime(req.Time)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	db, err := store.DB()
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	var version *Version
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(snapshotBucket))
+		if root == nil {
+			return nil
+		}
+		b := root.Bucket([]byte(objectKey(req.Group, req.Resource, req.NameSpace, req.Name)))
+		if b == nil {
+			return nil
+		}
+		b.ForEach(func(k, _ []byte) error {
+			resp.Versions = append(resp.Versions, formatKey(k))
+			return nil
+		})
+		version, err = objectAt(b, t)
+		return err
+	})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("读取快照失败: %v", err)
+		return
+	}
+	if version == nil {
+		resp.ErrorCode = "404"
+		resp.ErrorMessage = "这个时间点之前没有该对象的记录"
+		return
+	}
+	resp.SnapshotTime = version.Time
+	if version.Object == nil {
+		resp.Deleted = true
+		return
+	}
+	if resp.Yaml, err = k8s.ResourceToYAML(version.Object.Object); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
+		return
+	}
+}
+
+type DiffNamespaceRequest struct {
+	NameSpace string `json:"namespace"`
+	// RFC3339 格式，to 为空时表示现在
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type DiffNamespaceResponse struct {
+	handlers.ErrorResponse
+	Changes []Change `json:"changes"`
+}
+
+// 变化类型
+const (
+	ChangeAdded    = "Added"
+	ChangeModified = "Modified"
+	ChangeDeleted  = "Deleted"
+)
+
+type Change struct {
+	Group     string `json:"group"`
+	Resource  string `json:"resource"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Type      string `json:"type"`
+	Diff      string `json:"diff"`
+}
+
+// DiffNamespace 对比命名空间在两个时间点之间的变化
+func DiffNamespace(w http.ResponseWriter, r *http.Request) {
+	var resp DiffNamespaceResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DiffNamespaceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.From == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "from 不能为空"
+		return
+	}
+	from, err := parseTime(req.From)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	to, err := parseTime(req.To)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	db, err := store.DB()
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	err = db.View(func(tx *bolt.Tx) error {
+		root := tx.Bucket([]byte(snapshotBucket))
+		if root == nil {
+			return nil
+		}
+		return root.ForEach(func(k, _ []byte) error {
+			group, resource, namespace, name := parseObjectKey(string(k))
+			b := root.Bucket(k)
+			if b == nil || namespace != req.NameSpace {
+				return nil
+			}
+			before, err := objectAt(b, from)
+			if err != nil {
+				return err
+			}
+			after, err := objectAt(b, to)
+			if err != nil {
+				return err
+			}
+			change := Change{Group: group, Resource: resource, Namespace: namespace, Name: name}
+			switch {
+			case (before == nil || before.Object == nil) && (after == nil || after.Object == nil):
+				return nil
+			case before == nil || before.Object == nil:
+				change.Type = ChangeAdded
+				change.Kind = after.Object.GetKind()
+				change.Diff, err = k8s.ObjectDiff(nil, after.Object)
+			case after == nil || after.Object == nil:
+				change.Type = ChangeDeleted
+				change.Kind = before.Object.GetKind()
+				change.Diff, err = k8s.ObjectDiff(before.Object, nil)
+			case before.Time == after.Time:
+				return nil
+			default:
+				change.Kind = after.Object.GetKind()
+				change.Diff, err = k8s.ObjectDiff(before.Object, after.Object)
+				if change.Diff == "" {
+					return err
+				}
+				change.Type = ChangeModified
+			}
+			if err != nil {
+				return err
+			}
+			resp.Changes = append(resp.Changes, change)
+			return nil
+		})
+	})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("读取快照失败: %v", err)
+		return
+	}
+	sort.Slice(resp.Changes, func(i, j int) bool {
+		if resp.Changes[i].Kind != resp.Changes[j].Kind {
+			return resp.Changes[i].Kind < resp.Changes[j].Kind
+		}
+		return resp.Changes[i].Name < resp.Changes[j].Name
+	})
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	t, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return t, fmt.Errorf("无效的时间 %s，需要 RFC3339 格式: %v", value, err)
+	}
+	return t, nil
+}
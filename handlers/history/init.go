@@ -0,0 +1,5 @@
+package history
+
+func init() {
+	go run()
+}
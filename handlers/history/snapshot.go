@@ -0,0 +1,338 @@
+package history
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/k8s"
+	"k8s-manage-api/store"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// 本地存储中的 bucket。snapshotBucket 下每个对象一个子 bucket，key 为快照时间，
+// value 为对象的 JSON，空 value 表示对象在这个时间点已被删除
+const (
+	configBucket   = "snapshot-config"
+	configKey      = "config"
+	snapshotBucket = "snapshots"
+)
+
+// timeFormat 固定宽度，按字节排序即按时间排序
+const timeFormat = "2006-01-02T15:04:05.000000000Z"
+
+// Config 是快照配置
+type Config struct {
+	Resources []Resource `json:"resources"`
+	// 为空时记录所有命名空间
+	Namespaces []string `json:"namespaces"`
+	// 快照间隔，单位秒，默认 300
+	IntervalSeconds int `json:"intervalSeconds"`
+	// 保留天数，默认 7
+	RetentionDays int `json:"retentionDays"`
+}
+
+type Resource struct {
+	Group    string `json:"group"`
+	Version  string `json:"version"`
+	Resource string `json:"resource"`
+}
+
+func (r Resource) gvr() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: r.Group, Version: r.Version, Resource: r.Resource}
+}
+
+var defaultConfig = Config{
+	Resources: []Resource{
+		{Group: "apps", Version: "v1", Resource: "deployments"},
+		{Group: "apps", Version: "v1", Resource: "statefulsets"},
+		{Group: "apps", Version: "v1", Resource: "daemonsets"},
+		{Version: "v1", Resource: "services"},
+		{Version: "v1", Resource: "configmaps"},
+		{Group: "networking.k8s.io", Version: "v1", Resource: "ingresses"},
+	},
+	IntervalSeconds: 300,
+	RetentionDays:   7,
+}
+
+func getConfig() (Config, error) {
+	var c Config
+	found, err := store.Get(configBucket, configKey, &c)
+	if err != nil {
+		return c, fmt.Errorf("读取快照配置失败: %v", err)
+	}
+	if !found {
+		return defaultConfig, nil
+	}
+	return c, nil
+}
+
+func (c Config) interval() time.Duration {
+	if c.IntervalSeconds <= 0 {
+		return time.Duration(defaultConfig.IntervalSeconds) * time.Second
+	}
+	return time.Duration(c.IntervalSeconds) * time.Second
+}
+
+func (c Config) retention() time.Duration {
+	days := c.RetentionDays
+	if days <= 0 {
+		days = defaultConfig.RetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func (c Config) includes(namespace string) bool {
+	if len(c.Namespaces) == 0 {
+		return true
+	}
+	for _, ns := range c.Namespaces {
+		if ns == namespace {
+			return true
+		}
+	}
+	return false
+}
+
+// objectKey 是对象子 bucket 的名称
+func objectKey(group, resource, namespace, name string) string {
+	return strings.Join([]string{group, resource, namespace, name}, "/")
+}
+
+func parseObjectKey(key string) (group, resource, namespace, name string) {
+	parts := strings.SplitN(key, "/", 4)
+	if len(parts) != 4 {
+		return "", "", "", ""
+	}
+	return parts[0], parts[1], parts[2], parts[3]
+}
+
+var (
+	snapshotMu   sync.Mutex
+	lastSnapshot time.Time
+)
+
+func run() {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+	for range ticker.C {
+		c, err := getConfig()
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		snapshotMu.Lock()
+		last := lastSnapshot
+		snapshotMu.Unlock()
+		if time.Since(last) < c.interval() {
+			continue
+		}
+		if err := Snapshot(context.Background(), c); err != nil {
+			log.Printf("记录快照失败: %v", err)
+		}
+	}
+}
+
+// listPageSize 是快照时每次 list 的对象数，避免大集群一次把所有对象读入内存
+const listPageSize = 500
+
+// listPages 分页获取命名空间下的对象，namespace 为空时获取所有命名空间
+func listPages(ctx context.Context, gvr schema.GroupVersionResource, namespace string, fn func(obj *unstructured.Unstructured) error) error {
+	opts := metav1.ListOptions{Limit: listPageSize}
+	for {
+		list, err := k8s.GetDynamicClient().Resource(gvr).Namespace(namespace).List(ctx, opts)
+		if err != nil {
+			return err
+		}
+		for i := range list.Items {
+			if err := fn(&list.Items[i]); err != nil {
+				return err
+			}
+		}
+		if list.GetContinue() == "" {
+			return nil
+		}
+		opts.Continue = list.GetContinue()
+	}
+}
+
+// Snapshot 记录一次快照，只保存和上一次相比有变化的对象，以及被删除的对象
+func Snapshot(ctx context.Context, c Config) error {
+	snapshotMu.Lock()
+	defer snapshotMu.Unlock()
+	now := time.Now().UTC()
+	lastSnapshot = now
+
+	current := make(map[string][]byte)
+	// 获取失败的资源和命名空间不记录删除，避免误判。key 为 group/resource/namespace，namespace 为空表示所有命名空间
+	listed := make(map[string]bool)
+	namespaces := c.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []string{metav1.NamespaceAll}
+	}
+	var errs []string
+	for _, res := range c.Resources {
+		for _, ns := range namespaces {
+			err := listPages(ctx, res.gvr(), ns, func(obj *unstructured.Unstructured) error {
+				data, err := snapshotData(obj)
+				if err != nil {
+					return err
+				}
+				current[objectKey(res.Group, res.Resource, obj.GetNamespace(), obj.GetName())] = data
+				return nil
+			})
+			if err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %v", res.gvr().String(), err))
+				continue
+			}
+			listed[res.Group+"/"+res.Resource+"/"+ns] = true
+		}
+	}
+
+	db, err := store.DB()
+	if err != nil {
+		return err
+	}
+	ts := []byte(now.Format(timeFormat))
+	cutoff := []byte(now.Add(-c.retention()).Format(timeFormat))
+	err = db.Update(func(tx *bolt.Tx) error {
+		root, err := tx.CreateBucketIfNotExists([]byte(snapshotBucket))
+		if err != nil {
+			return err
+		}
+		// 新增或修改的对象
+		for key, data := range current {
+			b, err := root.CreateBucketIfNotExists([]byte(key))
+			if err != nil {
+				return err
+			}
+			_, last := b.Cursor().Last()
+			if last == nil || !bytes.Equal(last, data) {
+				if err := b.Put(ts, data); err != nil {
+					return err
+				}
+			}
+		}
+
+		// 删除的对象和过期的快照。遍历时不能修改 bucket，先取出所有对象
+		var keys [][]byte
+		if err := root.ForEach(func(k, _ []byte) error {
+			keys = append(keys, append([]byte{}, k...))
+			return nil
+		}); err != nil {
+			return err
+		}
+		var empty [][]byte
+		for _, k := range keys {
+			b := root.Bucket(k)
+			if b == nil {
+				continue
+			}
+			group, resource, namespace, _ := parseObjectKey(string(k))
+			wasListed := listed[group+"/"+resource+"/"+namespace] || listed[group+"/"+resource+"/"]
+			if _, ok := current[string(k)]; !ok && wasListed && c.includes(namespace) {
+				if _, last := b.Cursor().Last(); len(last) > 0 {
+					if err := b.Put(ts, []byte{}); err != nil {
+						return err
+					}
+				}
+			}
+			removed, err := expire(b, cutoff)
+			if err != nil {
+				return err
+			}
+			if removed {
+				empty = append(empty, k)
+			}
+		}
+		for _, k := range empty {
+			if err := root.DeleteBucket(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// expire 删除 cutoff 之前的快照，但保留 cutoff 之前的最后一个版本，用于查询 cutoff 之后的状态。
+// 只剩下一个删除标记时返回 true，调用方删除整个对象
+func expire(b *bolt.Bucket, cutoff []byte) (bool, error) {
+	var old [][]byte
+	c := b.Cursor()
+	for k, _ := c.First(); k != nil && bytes.Compare(k, cutoff) < 0; k, _ = c.Next() {
+		old = append(old, append([]byte{}, k...))
+	}
+	if len(old) > 1 {
+		for _, k := range old[:len(old)-1] {
+			if err := b.Delete(k); err != nil {
+				return false, err
+			}
+		}
+	}
+	first, value := b.Cursor().First()
+	next, _ := b.Cursor().Last()
+	return first != nil && bytes.Equal(first, next) && len(value) == 0 && bytes.Compare(first, cutoff) < 0, nil
+}
+
+// snapshotData 去掉每次都会变化的字段，只有 spec、metadata 等实际内容变化时才保存新版本
+func snapshotData(obj *unstructured.Unstructured) ([]byte, error) {
+	obj = obj.DeepCopy()
+	k8s.CleanObject(obj, k8s.CleanOptions{ManagedFields: true, ResourceVersion: true, Status: true})
+	unstructured.RemoveNestedField(obj.Object, "metadata", "generation")
+	return json.Marshal(obj.Object)
+}
+
+// Version 是对象在某个时间点的状态
+type Version struct {
+	Time string `json:"time"`
+	// 为 nil 表示对象在这个时间点已被删除
+	Object *unstructured.Unstructured `json:"-"`
+}
+
+// objectAt 返回对象在 t 时刻的状态，t 之前没有记录时返回 nil
+func objectAt(b *bolt.Bucket, t time.Time) (*Version, error) {
+	key := []byte(t.UTC().Format(timeFormat))
+	c := b.Cursor()
+	k, v := c.Seek(key)
+	if k == nil {
+		k, v = c.Last()
+	} else if !bytes.Equal(k, key) {
+		k, v = c.Prev()
+	}
+	if k == nil {
+		return nil, nil
+	}
+	version := &Version{Time: formatKey(k)}
+	if len(v) == 0 {
+		return version, nil
+	}
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(v); err != nil {
+		return nil, err
+	}
+	version.Object = obj
+	return version, nil
+}
+
+func formatKey(k []byte) string {
+	t, err := time.Parse(timeFormat, string(k))
+	if err != nil {
+		return string(k)
+	}
+	return t.Local().Format(time.RFC3339)
+}
@@ -0,0 +1,209 @@
+package handlers
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ListQuery 列表接口通用的分页、过滤和排序参数，嵌入到各个列表接口的请求结构体中
+type ListQuery struct {
+	// 每页条数，0 表示不分页
+	Limit int64 `json:"limit"`
+	// 上一页返回的 continue
+	Continue      string `json:"continue"`
+	LabelSelector string `json:"labelSelector"`
+	// 按名称过滤，默认为子串匹配，searchRegex 为 true 时按正则匹配
+	Search      string `json:"search"`
+	SearchRegex bool   `json:"searchRegex"`
+	// 按状态过滤，例如 Running、CrashLoopBackOff
+	Status []string `json:"status"`
+	// name、age、restarts 或 namespace
+	SortBy   string `json:"sortBy"`
+	SortDesc bool   `json:"sortDesc"`
+}
+
+// ListMeta 列表接口通用的分页信息，嵌入到各个列表接口的响应结构体中
+type ListMeta struct {
+	// 满足过滤条件的总数，无法确定时不返回。直接由 API Server 分页时，只有第一页、并且 API Server
+	// 返回了剩余数量时才能得到准确的总数：设置了 labelSelector 或 fieldSelector 时 API Server 不返回剩余数量，
+	// 而且剩余数量只统计当前页之后的对象
+	Total    *int   `json:"total,omitempty"`
+	Continue string `json:"continue"`
+}
+
+// ListItem 是过滤和排序需要的字段
+type ListItem struct {
+	Name         string
+	Namespace    string
+	Status       string
+	Restarts     int
+	CreationTime time.Time
+}
+
+// offsetContinuePrefix 标识由本服务在内存中分页生成的 continue
+const offsetContinuePrefix = "offset:"
+
+// serverSide 没有名称、状态过滤和排序时，分页直接交给 API Server 处理
+func (q ListQuery) serverSide() bool {
+	return q.Search == "" && len(q.Status) == 0 && q.SortBy == "" && !strings.HasPrefix(q.Continue, offsetContinuePrefix)
+}
+
+// ListOptions 返回带有标签选择器的 ListOptions，能交给 API Server 分页时同时带上 limit 和 continue
+func (q ListQuery) ListOptions() metav1.ListOptions {
+	opt := metav1.ListOptions{LabelSelector: q.LabelSelector}
+	if q.serverSide() {
+		opt.Limit = q.Limit
+		opt.Continue = q.Continue
+	}
+	return opt
+}
+
+// ApplyListQuery 对 API Server 返回的对象做名称、状态过滤、排序和分页。
+// 需要在内存中过滤或排序时，ListOptions 不会带 limit，这里再按 offset 分页
+func ApplyListQuery[T any](q ListQuery, items []T, listMeta metav1.ListMeta, item func(*T) ListItem) ([]T, ListMeta, error) {
+	if q.serverSide() {
+		return items, ServerSideListMeta(q, len(items), listMeta), nil
+	}
+
+	match, err := nameMatcher(q.Search, q.SearchRegex)
+	if err != nil {
+		return nil, ListMeta{}, err
+	}
+	status := make(map[string]bool)
+	for _, s := range q.Status {
+		status[strings.ToLower(s)] = true
+	}
+
+	type entry struct {
+		obj  T
+		item ListItem
+	}
+	var entries []entry
+	for i := range items {
+		it := item(&items[i])
+		if !match(it.Name) {
+			continue
+		}
+		if len(status) > 0 && !status[strings.ToLower(it.Status)] {
+			continue
+		}
+		entries = append(entries, entry{obj: items[i], item: it})
+	}
+
+	less, err := listLess(q.SortBy)
+	if err != nil {
+		return nil, ListMeta{}, err
+	}
+	if less != nil {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if q.SortDesc {
+				return less(entries[j].item, entries[i].item)
+			}
+			return less(entries[i].item, entries[j].item)
+		})
+	}
+
+	offset := 0
+	if q.Continue != "" {
+		offset, err = strconv.Atoi(strings.TrimPrefix(q.Continue, offsetContinuePrefix))
+		if err != nil || offset < 0 {
+			return nil, ListMeta{}, fmt.Errorf("无效的 continue: %s", q.Continue)
+		}
+	}
+	total := len(entries)
+	meta := ListMeta{Total: &total}
+	if offset > len(entries) {
+		offset = len(entries)
+	}
+	end := len(entries)
+	if q.Limit > 0 && offset+int(q.Limit) < end {
+		end = offset + int(q.Limit)
+		meta.Continue = offsetContinuePrefix + strconv.Itoa(end)
+	}
+
+	result := make([]T, 0, end-offset)
+	for _, e := range entries[offset:end] {
+		result = append(result, e.obj)
+	}
+	return result, meta, nil
+}
+
+// ServerSideListMeta 根据 API Server 分页返回的 ListMeta 计算分页信息，count 是当前页的对象数量，
+// 无法确定总数时不设置 Total
+func ServerSideListMeta(q ListQuery, count int, listMeta metav1.ListMeta) ListMeta {
+	meta := ListMeta{Continue: listMeta.Continue}
+	// 不是第一页时无法知道前面几页的数量
+	if q.Continue != "" {
+		return meta
+	}
+	switch {
+	case listMeta.Continue == "":
+		meta.Total = &count
+	case listMeta.RemainingItemCount != nil:
+		total := count + int(*listMeta.RemainingItemCount)
+		meta.Total = &total
+	}
+	return meta
+}
+
+func nameMatcher(search string, regex bool) (func(string) bool, error) {
+	if search == "" {
+		return func(string) bool { return true }, nil
+	}
+	if regex {
+		re, err := regexp.Compile(search)
+		if err != nil {
+			return nil, fmt.Errorf("无效的正则表达式 %s: %v", search, err)
+		}
+		return re.MatchString, nil
+	}
+	search = strings.ToLower(search)
+	return func(name string) bool {
+		return strings.Contains(strings.ToLower(name), search)
+	}, nil
+}
+
+// listLess 返回排序函数，名称相同时再按命名空间排序，保证结果稳定
+func listLess(sortBy string) (func(a, b ListItem) bool, error) {
+	byName := func(a, b ListItem) bool {
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Namespace < b.Namespace
+	}
+	switch sortBy {
+	case "":
+		return nil, nil
+	case "name":
+		return byName, nil
+	case "namespace":
+		return func(a, b ListItem) bool {
+			if a.Namespace != b.Namespace {
+				return a.Namespace < b.Namespace
+			}
+			return a.Name < b.Name
+		}, nil
+	case "age":
+		// 升序时最新创建的排在前面
+		return func(a, b ListItem) bool {
+			if !a.CreationTime.Equal(b.CreationTime) {
+				return a.CreationTime.After(b.CreationTime)
+			}
+			return byName(a, b)
+		}, nil
+	case "restarts":
+		return func(a, b ListItem) bool {
+			if a.Restarts != b.Restarts {
+				return a.Restarts < b.Restarts
+			}
+			return byName(a, b)
+		}, nil
+	}
+	return nil, fmt.Errorf("不支持的排序字段: %s", sortBy)
+}
@@ -8,15 +8,18 @@ import (
 	"k8s-manage-api/k8s"
 	"net/http"
 
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListNodeRequest struct {
+	handlers.ListQuery
 	NodeName string `json:"nodeName"`
 }
 
 type ListNodeResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Nodes []Node `json:"nodes"`
 }
 
@@ -42,9 +45,8 @@ func ListClusterNodes(w http.ResponseWriter, r *http.Request) {
 	if req.NodeName != "" {
 		fieldSelector = fmt.Sprintf("metadata.name=%s", req.NodeName)
 	}
-	listOptions := metav1.ListOptions{
-		FieldSelector: fieldSelector,
-	}
+	listOptions := req.ListOptions()
+	listOptions.FieldSelector = fieldSelector
 	// 正确顺序：先获取节点列表
 	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), listOptions)
 	if err != nil {
@@ -55,7 +57,21 @@ func ListClusterNodes(w http.ResponseWriter, r *http.Request) {
 
 	// 处理节点数据
 	nodeList := make([]Node, 0)
-	for _, node := range nodes.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, nodes.Items, nodes.ListMeta, func(node *corev1.Node) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         node.Name,
+			Namespace:    node.Namespace,
+			CreationTime: node.CreationTimestamp.Time,
+			Status:       GetNodeStatus(*node),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, node := range items {
 		nodeList = append(nodeList, getNodes(node))
 	}
 	resp.Nodes = nodeList
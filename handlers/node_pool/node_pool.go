@@ -106,14 +106,8 @@ func ListNodePool(w http.ResponseWriter, r *http.Request) {
 			// 创建节点信息
 			nodeInfo := getNodes(node)
 
-			// 获取节点标签
-			labels := node.Labels
-			nodePoolKey := "default"
-			for l, v := range labels {
-				if strings.Contains(l, "nodepool") || strings.Contains(l, "nodegroup") {
-					nodePoolKey = v
-				}
-			}
+			// 获取节点所属的节点池
+			nodePoolKey := NodePoolName(node)
 			lock.Lock()
 			defer lock.Unlock()
 			// 如果节点池不存在，创建新的节点池
@@ -144,6 +138,17 @@ func ListNodePool(w http.ResponseWriter, r *http.Request) {
 	return
 }
 
+// NodePoolName 根据节点标签判断节点所属的节点池，没有节点池标签的节点属于 default
+func NodePoolName(node corev1.Node) string {
+	nodePoolKey := "default"
+	for l, v := range node.Labels {
+		if strings.Contains(l, "nodepool") || strings.Contains(l, "nodegroup") {
+			nodePoolKey = v
+		}
+	}
+	return nodePoolKey
+}
+
 func getNodes(node corev1.Node) Node {
 	// 按标签分组节点
 	return Node{
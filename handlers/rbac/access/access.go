@@ -0,0 +1,329 @@
+package access
+
+import (
+	"context"
+	"fmt"
+	"k8s-manage-api/k8s"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Snapshot 是集群中所有 Role、ClusterRole 和绑定，用于在本地计算权限
+type Snapshot struct {
+	Roles               map[string]*rbacv1.Role
+	ClusterRoles        map[string]*rbacv1.ClusterRole
+	RoleBindings        []rbacv1.RoleBinding
+	ClusterRoleBindings []rbacv1.ClusterRoleBinding
+
+	// 展开聚合后的 ClusterRole 规则
+	clusterRoleRules map[string][]SourcedRule
+}
+
+// SourcedRule 是一条规则以及它实际所在的 ClusterRole，聚合 ClusterRole 的规则来自被聚合的 ClusterRole
+type SourcedRule struct {
+	rbacv1.PolicyRule
+	// 规则所在的 ClusterRole，与引用的角色相同时为空
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
+}
+
+// Ref 引用一个 RBAC 对象
+type Ref struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// Grant 是通过某个绑定授予某个主体的一条规则
+type Grant struct {
+	Subject rbacv1.Subject `json:"subject"`
+	// 生效的命名空间，为空表示所有命名空间（ClusterRoleBinding）
+	Namespace string      `json:"namespace"`
+	Binding   Ref         `json:"binding"`
+	Role      Ref         `json:"role"`
+	Rule      SourcedRule `json:"rule"`
+}
+
+func roleKey(namespace, name string) string {
+	return namespace + "/" + name
+}
+
+// Load 读取集群中所有 RBAC 对象
+func Load(ctx context.Context) (*Snapshot, error) {
+	rbac := k8s.GetClient().RbacV1()
+	roles, err := rbac.Roles("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 Role 列表失败: %v", err)
+	}
+	clusterRoles, err := rbac.ClusterRoles().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 ClusterRole 列表失败: %v", err)
+	}
+	roleBindings, err := rbac.RoleBindings("").List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 RoleBinding 列表失败: %v", err)
+	}
+	clusterRoleBindings, err := rbac.ClusterRoleBindings().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("获取 ClusterRoleBinding 列表失败: %v", err)
+	}
+
+	s := &Snapshot{
+		Roles:               make(map[string]*rbacv1.Role, len(roles.Items)),
+		ClusterRoles:        make(map[string]*rbacv1.ClusterRole, len(clusterRoles.Items)),
+		RoleBindings:        roleBindings.Items,
+		ClusterRoleBindings: clusterRoleBindings.Items,
+		clusterRoleRules:    make(map[string][]SourcedRule),
+	}
+	for i := range roles.Items {
+		role := &roles.Items[i]
+		s.Roles[roleKey(role.Namespace, role.Name)] = role
+	}
+	for i := range clusterRoles.Items {
+		s.ClusterRoles[clusterRoles.Items[i].Name] = &clusterRoles.Items[i]
+	}
+	return s, nil
+}
+
+// ClusterRoleRules 返回 ClusterRole 的规则。聚合 ClusterRole 的规则由所有匹配 aggregationRule
+// 的 ClusterRole 计算得出，不依赖控制器是否已经同步
+func (s *Snapshot) ClusterRoleRules(name string) []SourcedRule {
+	return s.clusterRoleRulesVisited(name, make(map[string]bool))
+}
+
+func (s *Snapshot) clusterRoleRulesVisited(name string, visited map[string]bool) []SourcedRule {
+	if rules, ok := s.clusterRoleRules[name]; ok {
+		return rules
+	}
+	cr, ok := s.ClusterRoles[name]
+	if !ok || visited[name] {
+		return nil
+	}
+	visited[name] = true
+
+	var rules []SourcedRule
+	if cr.AggregationRule == nil || len(cr.AggregationRule.ClusterRoleSelectors) == 0 {
+		for _, rule := range cr.Rules {
+			rules = append(rules, SourcedRule{PolicyRule: rule})
+		}
+	} else {
+		for _, other := range s.aggregatedClusterRoles(cr) {
+			for _, rule := range s.clusterRoleRulesVisited(other, visited) {
+				if rule.AggregatedFrom == "" {
+					rule.AggregatedFrom = other
+				}
+				rules = append(rules, rule)
+			}
+		}
+	}
+	s.clusterRoleRules[name] = rules
+	return rules
+}
+
+// aggregatedClusterRoles 返回标签匹配聚合 ClusterRole 的 aggregationRule 的所有 ClusterRole，按名称排序
+func (s *Snapshot) aggregatedClusterRoles(cr *rbacv1.ClusterRole) []string {
+	if cr.AggregationRule == nil {
+		return nil
+	}
+	var selectors []labels.Selector
+	for i := range cr.AggregationRule.ClusterRoleSelectors {
+		selector, err := metav1.LabelSelectorAsSelector(&cr.AggregationRule.ClusterRoleSelectors[i])
+		if err == nil {
+			selectors = append(selectors, selector)
+		}
+	}
+	var names []string
+	for name, other := range s.ClusterRoles {
+		if name == cr.Name {
+			continue
+		}
+		for _, selector := range selectors {
+			if selector.Matches(labels.Set(other.Labels)) {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// roleRefRules 返回绑定引用的角色和它的规则，RoleBinding 可以引用 ClusterRole
+func (s *Snapshot) roleRefRules(namespace string, ref rbacv1.RoleRef) (Ref, metav1.Time, []SourcedRule) {
+	if ref.Kind == "ClusterRole" {
+		var created metav1.Time
+		if cr, ok := s.ClusterRoles[ref.Name]; ok {
+			created = cr.CreationTimestamp
+		}
+		return Ref{Kind: "ClusterRole", Name: ref.Name}, created, s.ClusterRoleRules(ref.Name)
+	}
+	var created metav1.Time
+	var rules []SourcedRule
+	if role, ok := s.Roles[roleKey(namespace, ref.Name)]; ok {
+		created = role.CreationTimestamp
+		for _, rule := range role.Rules {
+			rules = append(rules, SourcedRule{PolicyRule: rule})
+		}
+	}
+	return Ref{Kind: "Role", Name: ref.Name, Namespace: namespace}, created, rules
+}
+
+// Binding 是至少有一个主体匹配的绑定，以及它引用的角色的规则
+type Binding struct {
+	Binding        Ref         `json:"binding"`
+	BindingCreated metav1.Time `json:"bindingCreated"`
+	Role           Ref         `json:"role"`
+	// 引用的角色不存在时为零值
+	RoleCreated metav1.Time `json:"roleCreated"`
+	// 匹配的主体
+	Subjects []rbacv1.Subject `json:"subjects"`
+	Rules    []SourcedRule    `json:"rules"`
+	// 生效的命名空间，为空表示所有命名空间
+	Namespace string `json:"namespace"`
+}
+
+// Bindings 返回主体匹配 match 的所有绑定
+func (s *Snapshot) Bindings(match func(rbacv1.Subject) bool) []Binding {
+	var bindings []Binding
+	for _, crb := range s.ClusterRoleBindings {
+		subjects := matchSubjects(crb.Subjects, "", match)
+		if len(subjects) == 0 {
+			continue
+		}
+		role, roleCreated, rules := s.roleRefRules("", crb.RoleRef)
+		bindings = append(bindings, Binding{
+			Binding:        Ref{Kind: "ClusterRoleBinding", Name: crb.Name},
+			BindingCreated: crb.CreationTimestamp,
+			Role:           role,
+			RoleCreated:    roleCreated,
+			Subjects:       subjects,
+			Rules:          rules,
+		})
+	}
+	for _, rb := range s.RoleBindings {
+		subjects := matchSubjects(rb.Subjects, rb.Namespace, match)
+		if len(subjects) == 0 {
+			continue
+		}
+		role, roleCreated, rules := s.roleRefRules(rb.Namespace, rb.RoleRef)
+		bindings = append(bindings, Binding{
+			Binding:        Ref{Kind: "RoleBinding", Name: rb.Name, Namespace: rb.Namespace},
+			BindingCreated: rb.CreationTimestamp,
+			Role:           role,
+			RoleCreated:    roleCreated,
+			Subjects:       subjects,
+			Rules:          rules,
+			Namespace:      rb.Namespace,
+		})
+	}
+	return bindings
+}
+
+func matchSubjects(subjects []rbacv1.Subject, namespace string, match func(rbacv1.Subject) bool) []rbacv1.Subject {
+	var matched []rbacv1.Subject
+	for _, subject := range subjects {
+		// RoleBinding 中 ServiceAccount 没有写命名空间时使用 RoleBinding 的命名空间
+		if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+			subject.Namespace = namespace
+		}
+		if match(subject) {
+			matched = append(matched, subject)
+		}
+	}
+	return matched
+}
+
+// Grants 展开所有绑定，返回每个主体获得的每条规则
+func (s *Snapshot) Grants() []Grant {
+	var grants []Grant
+	for _, b := range s.Bindings(func(rbacv1.Subject) bool { return true }) {
+		for _, subject := range b.Subjects {
+			for _, rule := range b.Rules {
+				grants = append(grants, Grant{Subject: subject, Namespace: b.Namespace, Binding: b.Binding, Role: b.Role, Rule: rule})
+			}
+		}
+	}
+	return grants
+}
+
+// Request 是一次资源访问或非资源 URL 访问
+type Request struct {
+	Verb        string `json:"verb"`
+	APIGroup    string `json:"apiGroup"`
+	Resource    string `json:"resource"`
+	Subresource string `json:"subresource"`
+	Name        string `json:"name"`
+	Namespace   string `json:"namespace"`
+	// 不为空时表示非资源 URL，例如 /healthz
+	NonResourceURL string `json:"nonResourceURL"`
+}
+
+// Allows 判断规则是否允许请求，与 API Server 的 RBAC 鉴权规则一致
+func Allows(rule rbacv1.PolicyRule, req Request) bool {
+	if !matches(rule.Verbs, req.Verb) {
+		return false
+	}
+	if req.NonResourceURL != "" {
+		return nonResourceURLMatches(rule.NonResourceURLs, req.NonResourceURL)
+	}
+	return matches(rule.APIGroups, req.APIGroup) &&
+		resourceMatches(rule.Resources, req.Resource, req.Subresource) &&
+		(len(rule.ResourceNames) == 0 || contains(rule.ResourceNames, req.Name))
+}
+
+// AppliesTo 判断授权在请求的命名空间是否生效，ClusterRoleBinding 对所有命名空间和集群级资源生效
+func (g Grant) AppliesTo(namespace string) bool {
+	return g.Namespace == "" || g.Namespace == namespace
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func matches(values []string, value string) bool {
+	return contains(values, rbacv1.VerbAll) || contains(values, value)
+}
+
+func resourceMatches(resources []string, resource, subresource string) bool {
+	combined := resource
+	if subresource != "" {
+		combined = resource + "/" + subresource
+	}
+	for _, r := range resources {
+		switch {
+		case r == rbacv1.ResourceAll, r == combined:
+			return true
+		case subresource != "" && r == "*/"+subresource:
+			return true
+		}
+	}
+	return false
+}
+
+func nonResourceURLMatches(urls []string, url string) bool {
+	for _, u := range urls {
+		if u == rbacv1.NonResourceAll || u == url {
+			return true
+		}
+		if strings.HasSuffix(u, "*") && strings.HasPrefix(url, strings.TrimSuffix(u, "*")) {
+			return true
+		}
+	}
+	return false
+}
+
+// SubjectKey 返回主体的唯一标识
+func SubjectKey(subject rbacv1.Subject) string {
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		return subject.Kind + ":" + subject.Namespace + "/" + subject.Name
+	}
+	return subject.Kind + ":" + subject.Name
+}
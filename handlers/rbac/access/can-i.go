@@ -0,0 +1,285 @@
+package access
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	authorizationv1 "k8s.io/api/authorization/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// defaultMatrixVerbs 矩阵模式默认检查的操作
+var defaultMatrixVerbs = []string{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// reviewConcurrency 并发发起 SubjectAccessReview 的数量
+const reviewConcurrency = 10
+
+type CanIRequest struct {
+	// 为空时使用 SelfSubjectAccessReview 检查本服务自己的权限
+	Subject *Subject `json:"subject"`
+	Checks  []Check  `json:"checks"`
+	// 矩阵模式，检查所有资源支持的操作
+	Matrix bool `json:"matrix"`
+	// 矩阵模式下检查的命名空间，集群级资源忽略
+	NameSpace string `json:"namespace"`
+	// 矩阵模式下检查的操作，默认 get、list、watch、create、update、patch、delete
+	Verbs []string `json:"verbs"`
+}
+
+// Subject 是需要检查权限的主体
+type Subject struct {
+	// User、Group 或 ServiceAccount
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// User 所属的组
+	Groups []string `json:"groups"`
+}
+
+type Check struct {
+	Verb string `json:"verb"`
+	// 可以写成 pods/exec 的形式表示子资源
+	Resource       string `json:"resource"`
+	APIGroup       string `json:"apiGroup"`
+	NameSpace      string `json:"namespace"`
+	ResourceName   string `json:"resourceName"`
+	NonResourceURL string `json:"nonResourceURL"`
+}
+
+type CheckResult struct {
+	Check
+	Allowed bool   `json:"allowed"`
+	Denied  bool   `json:"denied"`
+	Reason  string `json:"reason"`
+	Error   string `json:"error,omitempty"`
+}
+
+type MatrixRow struct {
+	APIGroup   string                 `json:"apiGroup"`
+	Resource   string                 `json:"resource"`
+	Kind       string                 `json:"kind"`
+	Namespaced bool                   `json:"namespaced"`
+	Verbs      map[string]CheckResult `json:"verbs"`
+}
+
+type CanIResponse struct {
+	handlers.ErrorResponse
+	Results []CheckResult `json:"results"`
+	Matrix  []MatrixRow   `json:"matrix,omitempty"`
+}
+
+// ServiceAccountUser 返回 ServiceAccount 认证后的用户名
+func ServiceAccountUser(namespace, name string) string {
+	return fmt.Sprintf("system:serviceaccount:%s:%s", namespace, name)
+}
+
+// ServiceAccountGroups 返回 ServiceAccount 认证后所属的组
+func ServiceAccountGroups(namespace string) []string {
+	return []string{"system:serviceaccounts", "system:serviceaccounts:" + namespace, "system:authenticated"}
+}
+
+// CanI 通过 SubjectAccessReview 检查用户、组或 ServiceAccount 是否有权限执行一组操作，
+// 矩阵模式下检查所有资源支持的操作
+func CanI(w http.ResponseWriter, r *http.Request) {
+	var resp CanIResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req CanIRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if len(req.Checks) == 0 && !req.Matrix {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "checks 不能为空"
+		return
+	}
+	for _, c := range req.Checks {
+		if c.Verb == "" || (c.Resource == "" && c.NonResourceURL == "") {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = "verb 和 resource 不能为空"
+			return
+		}
+	}
+
+	var review reviewer
+	if req.Subject != nil {
+		var err error
+		review, err = subjectReviewer(r.Context(), *req.Subject)
+		if err != nil {
+			resp.ErrorCode = "500"
+			if errors.IsNotFound(err) || errors.IsBadRequest(err) {
+				resp.ErrorCode = "400"
+			}
+			resp.ErrorMessage = err.Error()
+			return
+		}
+	} else {
+		review = selfReview
+	}
+
+	checks := req.Checks
+	var matrix []MatrixRow
+	if req.Matrix {
+		discovered, err := handlers.DiscoverResources()
+		if err != nil {
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = err.Error()
+			return
+		}
+		verbs := req.Verbs
+		if len(verbs) == 0 {
+			verbs = defaultMatrixVerbs
+		}
+		sort.Slice(discovered, func(i, j int) bool {
+			if discovered[i].Group != discovered[j].Group {
+				return discovered[i].Group < discovered[j].Group
+			}
+			return discovered[i].Resource < discovered[j].Resource
+		})
+		for _, d := range discovered {
+			row := MatrixRow{APIGroup: d.Group, Resource: d.Resource, Kind: d.Kind, Namespaced: d.Namespaced}
+			namespace := ""
+			if d.Namespaced {
+				namespace = req.NameSpace
+			}
+			for _, verb := range verbs {
+				if d.HasVerbs(verb) {
+					checks = append(checks, Check{Verb: verb, Resource: d.Resource, APIGroup: d.Group, NameSpace: namespace})
+				}
+			}
+			matrix = append(matrix, row)
+		}
+	}
+
+	results := runChecks(r.Context(), review, checks)
+	resp.Results = results[:len(req.Checks)]
+	if req.Matrix {
+		// 矩阵的检查按资源顺序追加在 checks 之后
+		rest := results[len(req.Checks):]
+		for i := range matrix {
+			matrix[i].Verbs = make(map[string]CheckResult)
+			for len(rest) > 0 && rest[0].Resource == matrix[i].Resource && rest[0].APIGroup == matrix[i].APIGroup {
+				matrix[i].Verbs[rest[0].Verb] = rest[0]
+				rest = rest[1:]
+			}
+		}
+		resp.Matrix = matrix
+	}
+}
+
+// reviewer 发起一次访问检查
+type reviewer func(ctx context.Context, attrs authorizationv1.ResourceAttributes, nonResource *authorizationv1.NonResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error)
+
+func subjectReviewer(ctx context.Context, subject Subject) (reviewer, error) {
+	spec := authorizationv1.SubjectAccessReviewSpec{}
+	switch subject.Kind {
+	case rbacv1.UserKind:
+		spec.User = subject.Name
+		spec.Groups = subject.Groups
+	case rbacv1.GroupKind:
+		spec.Groups = []string{subject.Name}
+	case rbacv1.ServiceAccountKind:
+		// 确认 ServiceAccount 存在，避免拼错名称时返回的全是拒绝
+		if _, err := k8s.GetClient().CoreV1().ServiceAccounts(subject.Namespace).Get(ctx, subject.Name, metav1.GetOptions{}); err != nil {
+			return nil, fmt.Errorf("获取ServiceAccount失败: %w", err)
+		}
+		spec.User = ServiceAccountUser(subject.Namespace, subject.Name)
+		spec.Groups = ServiceAccountGroups(subject.Namespace)
+	default:
+		return nil, errors.NewBadRequest(fmt.Sprintf("不支持的主体类型: %s", subject.Kind))
+	}
+	if subject.Name == "" {
+		return nil, errors.NewBadRequest("subject.name 不能为空")
+	}
+
+	return func(ctx context.Context, attrs authorizationv1.ResourceAttributes, nonResource *authorizationv1.NonResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+		sar := &authorizationv1.SubjectAccessReview{Spec: spec}
+		if nonResource != nil {
+			sar.Spec.NonResourceAttributes = nonResource
+		} else {
+			sar.Spec.ResourceAttributes = &attrs
+		}
+		result, err := k8s.GetClient().AuthorizationV1().SubjectAccessReviews().Create(ctx, sar, metav1.CreateOptions{})
+		if err != nil {
+			return authorizationv1.SubjectAccessReviewStatus{}, err
+		}
+		return result.Status, nil
+	}, nil
+}
+
+func selfReview(ctx context.Context, attrs authorizationv1.ResourceAttributes, nonResource *authorizationv1.NonResourceAttributes) (authorizationv1.SubjectAccessReviewStatus, error) {
+	ssar := &authorizationv1.SelfSubjectAccessReview{}
+	if nonResource != nil {
+		ssar.Spec.NonResourceAttributes = nonResource
+	} else {
+		ssar.Spec.ResourceAttributes = &attrs
+	}
+	result, err := k8s.GetClient().AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, ssar, metav1.CreateOptions{})
+	if err != nil {
+		return authorizationv1.SubjectAccessReviewStatus{}, err
+	}
+	return result.Status, nil
+}
+
+// runChecks 并发执行所有检查，结果顺序与 checks 一致
+func runChecks(ctx context.Context, review reviewer, checks []Check) []CheckResult {
+	results := make([]CheckResult, len(checks))
+	sem := make(chan struct{}, reviewConcurrency)
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, c Check) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i] = runCheck(ctx, review, c)
+		}(i, c)
+	}
+	wg.Wait()
+	return results
+}
+
+func runCheck(ctx context.Context, review reviewer, c Check) CheckResult {
+	result := CheckResult{Check: c}
+	var status authorizationv1.SubjectAccessReviewStatus
+	var err error
+	if c.NonResourceURL != "" {
+		status, err = review(ctx, authorizationv1.ResourceAttributes{}, &authorizationv1.NonResourceAttributes{Path: c.NonResourceURL, Verb: c.Verb})
+	} else {
+		resource, subresource, _ := strings.Cut(c.Resource, "/")
+		status, err = review(ctx, authorizationv1.ResourceAttributes{
+			Namespace:   c.NameSpace,
+			Verb:        c.Verb,
+			Group:       c.APIGroup,
+			Resource:    resource,
+			Subresource: subresource,
+			Name:        c.ResourceName,
+		}, nil)
+	}
+	if err != nil {
+		result.Error = fmt.Sprintf("访问检查失败: %v", err)
+		return result
+	}
+	result.Allowed = status.Allowed
+	result.Denied = status.Denied
+	result.Reason = status.Reason
+	if status.EvaluationError != "" {
+		result.Error = status.EvaluationError
+	}
+	return result
+}
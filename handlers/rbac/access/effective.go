@@ -0,0 +1,162 @@
+package access
+
+import (
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// AllNamespaces 表示通过 ClusterRoleBinding 获得、对所有命名空间和集群级资源生效的权限
+const AllNamespaces = "*"
+
+// Permissions 是一个主体合并所有绑定后的有效权限
+type Permissions struct {
+	Namespaces      []NamespacePermissions  `json:"namespaces"`
+	NonResourceURLs []NonResourcePermission `json:"nonResourceURLs"`
+}
+
+// NamespacePermissions 是某个命名空间内的资源 × 操作矩阵
+type NamespacePermissions struct {
+	// 命名空间，* 表示所有命名空间
+	Namespace string               `json:"namespace"`
+	Resources []ResourcePermission `json:"resources"`
+}
+
+// ResourcePermission 是矩阵的一行，resourceNames 不同的规则分开成不同的行
+type ResourcePermission struct {
+	APIGroup string `json:"apiGroup"`
+	// 资源，可能是 *、pods/exec 或 */scale
+	Resource string `json:"resource"`
+	// 为空表示所有对象
+	ResourceNames []string `json:"resourceNames,omitempty"`
+	// 操作 -> 授予这个操作的来源
+	Verbs map[string][]Provenance `json:"verbs"`
+}
+
+type NonResourcePermission struct {
+	URL   string                  `json:"url"`
+	Verbs map[string][]Provenance `json:"verbs"`
+}
+
+// Provenance 是矩阵中一个单元格的来源
+type Provenance struct {
+	Subject rbacv1.Subject `json:"subject"`
+	Binding Ref            `json:"binding"`
+	Role    Ref            `json:"role"`
+	// 规则所在的 ClusterRole，与 role 相同时为空
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
+}
+
+// ServiceAccountMatcher 返回判断绑定主体是否适用于 ServiceAccount 的函数，
+// 除了 ServiceAccount 本身，还包括对应的用户名和 ServiceAccount 所在的组
+func ServiceAccountMatcher(namespace, name string) func(rbacv1.Subject) bool {
+	user := ServiceAccountUser(namespace, name)
+	groups := ServiceAccountGroups(namespace)
+	return func(subject rbacv1.Subject) bool {
+		switch subject.Kind {
+		case rbacv1.ServiceAccountKind:
+			return subject.Namespace == namespace && subject.Name == name
+		case rbacv1.UserKind:
+			return subject.Name == user
+		case rbacv1.GroupKind:
+			return contains(groups, subject.Name)
+		}
+		return false
+	}
+}
+
+// EffectivePermissions 合并主体匹配 match 的所有绑定，得到去重后每个命名空间的资源 × 操作矩阵
+func (s *Snapshot) EffectivePermissions(match func(rbacv1.Subject) bool) Permissions {
+	namespaces := make(map[string]map[string]*ResourcePermission)
+	nonResource := make(map[string]*NonResourcePermission)
+	seen := make(map[string]bool)
+
+	add := func(verbs map[string][]Provenance, cellKey, verb string, p Provenance) {
+		key := cellKey + "|" + verb + "|" + SubjectKey(p.Subject) + "|" + p.Binding.Kind + "/" + p.Binding.Namespace + "/" + p.Binding.Name + "|" + p.AggregatedFrom
+		if seen[key] {
+			return
+		}
+		seen[key] = true
+		verbs[verb] = append(verbs[verb], p)
+	}
+
+	for _, b := range s.Bindings(match) {
+		namespace := b.Namespace
+		if namespace == "" {
+			namespace = AllNamespaces
+		}
+		for _, rule := range b.Rules {
+			for _, subject := range b.Subjects {
+				p := Provenance{Subject: subject, Binding: b.Binding, Role: b.Role, AggregatedFrom: rule.AggregatedFrom}
+
+				// 非资源 URL 只在 ClusterRoleBinding 中生效
+				if b.Namespace == "" {
+					for _, url := range rule.NonResourceURLs {
+						perm, ok := nonResource[url]
+						if !ok {
+							perm = &NonResourcePermission{URL: url, Verbs: make(map[string][]Provenance)}
+							nonResource[url] = perm
+						}
+						for _, verb := range rule.Verbs {
+							add(perm.Verbs, "url|"+url, verb, p)
+						}
+					}
+				}
+
+				names := append([]string(nil), rule.ResourceNames...)
+				sort.Strings(names)
+				for _, group := range rule.APIGroups {
+					for _, resource := range rule.Resources {
+						cellKey := strings.Join([]string{namespace, group, resource, strings.Join(names, ",")}, "|")
+						if namespaces[namespace] == nil {
+							namespaces[namespace] = make(map[string]*ResourcePermission)
+						}
+						perm, ok := namespaces[namespace][cellKey]
+						if !ok {
+							perm = &ResourcePermission{APIGroup: group, Resource: resource, ResourceNames: names, Verbs: make(map[string][]Provenance)}
+							namespaces[namespace][cellKey] = perm
+						}
+						for _, verb := range rule.Verbs {
+							add(perm.Verbs, cellKey, verb, p)
+						}
+					}
+				}
+			}
+		}
+	}
+
+	var perms Permissions
+	for namespace, resources := range namespaces {
+		np := NamespacePermissions{Namespace: namespace}
+		for _, perm := range resources {
+			np.Resources = append(np.Resources, *perm)
+		}
+		sort.Slice(np.Resources, func(i, j int) bool {
+			a, b := np.Resources[i], np.Resources[j]
+			if a.APIGroup != b.APIGroup {
+				return a.APIGroup < b.APIGroup
+			}
+			if a.Resource != b.Resource {
+				return a.Resource < b.Resource
+			}
+			return strings.Join(a.ResourceNames, ",") < strings.Join(b.ResourceNames, ",")
+		})
+		perms.Namespaces = append(perms.Namespaces, np)
+	}
+	// 所有命名空间的权限排在最前面
+	sort.Slice(perms.Namespaces, func(i, j int) bool {
+		a, b := perms.Namespaces[i].Namespace, perms.Namespaces[j].Namespace
+		if (a == AllNamespaces) != (b == AllNamespaces) {
+			return a == AllNamespaces
+		}
+		return a < b
+	})
+	for _, perm := range nonResource {
+		perms.NonResourceURLs = append(perms.NonResourceURLs, *perm)
+	}
+	sort.Slice(perms.NonResourceURLs, func(i, j int) bool {
+		return perms.NonResourceURLs[i].URL < perms.NonResourceURLs[j].URL
+	})
+	return perms
+}
@@ -0,0 +1,493 @@
+package access
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// 风险等级及对应的分数
+const (
+	SeverityCritical = "Critical"
+	SeverityHigh     = "High"
+	SeverityMedium   = "Medium"
+	SeverityLow      = "Low"
+)
+
+var severityScore = map[string]int{
+	SeverityCritical: 10,
+	SeverityHigh:     7,
+	SeverityMedium:   4,
+	SeverityLow:      1,
+}
+
+// 风险类型
+const (
+	RiskWildcardVerbs      = "WildcardVerbs"
+	RiskWildcardResources  = "WildcardResources"
+	RiskSecretsRead        = "SecretsRead"
+	RiskPodExec            = "PodExec"
+	RiskEscalate           = "Escalate"
+	RiskBind               = "Bind"
+	RiskImpersonate        = "Impersonate"
+	RiskNodesProxy         = "NodesProxy"
+	RiskKubeSystemWorkload = "KubeSystemWorkload"
+	RiskAnonymousBinding   = "AnonymousBinding"
+)
+
+// workloadResources 可以用来在 kube-system 中运行任意代码的资源
+var workloadResources = []struct{ group, resource string }{
+	{"", "pods"},
+	{"", "replicationcontrollers"},
+	{"apps", "deployments"},
+	{"apps", "daemonsets"},
+	{"apps", "statefulsets"},
+	{"apps", "replicasets"},
+	{"batch", "jobs"},
+	{"batch", "cronjobs"},
+}
+
+// Finding 是一个主体通过某个绑定获得的危险权限
+type Finding struct {
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+	// 生效的命名空间，* 表示所有命名空间
+	Namespace string `json:"namespace"`
+	// 未绑定角色的发现没有绑定
+	Binding *Ref `json:"binding,omitempty"`
+	Role    Ref  `json:"role"`
+	// 绑定到未认证主体的发现没有对应的规则
+	Rule *SourcedRule `json:"rule,omitempty"`
+}
+
+// SubjectRisk 是一个主体的风险评分和所有发现
+type SubjectRisk struct {
+	Kind      string    `json:"kind"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace,omitempty"`
+	Score     int       `json:"score"`
+	Severity  string    `json:"severity"`
+	Findings  []Finding `json:"findings"`
+}
+
+// UnboundRoleRisk 是没有被任何绑定引用、也没有被聚合到其它 ClusterRole 的危险角色，
+// 目前不授予任何主体权限，但随时可能被绑定
+type UnboundRoleRisk struct {
+	Role     Ref       `json:"role"`
+	Score    int       `json:"score"`
+	Severity string    `json:"severity"`
+	Findings []Finding `json:"findings"`
+}
+
+type RiskReport struct {
+	GeneratedAt  time.Time         `json:"generatedAt"`
+	Subjects     []SubjectRisk     `json:"subjects"`
+	UnboundRoles []UnboundRoleRisk `json:"unboundRoles"`
+}
+
+type RiskScanRequest struct {
+	// 忽略 kube-system 中的 ServiceAccount、节点和 kube-* 组件使用的主体，以及 system: 开头的未绑定 ClusterRole
+	// 和 kube-system 中的未绑定 Role
+	ExcludeSystem bool `json:"excludeSystem"`
+	// 只返回分数不低于 minScore 的主体和未绑定角色
+	MinScore int `json:"minScore"`
+}
+
+type RiskScanResponse struct {
+	handlers.ErrorResponse
+	RiskReport
+}
+
+type RiskExportRequest struct {
+	RiskScanRequest
+	// json 或 csv，默认 json
+	Format string `json:"format"`
+}
+
+// covers 判断规则是否覆盖某类请求，resourceNames 限定的规则也算覆盖
+func covers(rule rbacv1.PolicyRule, verb, group, resource, subresource string) bool {
+	return matches(rule.Verbs, verb) && matches(rule.APIGroups, group) && resourceMatches(rule.Resources, resource, subresource)
+}
+
+// ruleFindings 检查一条规则的危险权限
+func ruleFindings(rule rbacv1.PolicyRule, namespace string) []Finding {
+	var findings []Finding
+	add := func(riskType, severity, message string) {
+		if len(rule.ResourceNames) > 0 && riskType != RiskWildcardVerbs {
+			message += fmt.Sprintf("（限定对象: %s）", strings.Join(rule.ResourceNames, ","))
+		}
+		findings = append(findings, Finding{Type: riskType, Severity: severity, Message: message})
+	}
+
+	if contains(rule.Verbs, rbacv1.VerbAll) {
+		add(RiskWildcardVerbs, SeverityCritical, "允许所有操作 (verbs: *)")
+	}
+	if len(rule.Resources) > 0 && (contains(rule.Resources, rbacv1.ResourceAll) || contains(rule.APIGroups, rbacv1.APIGroupAll)) {
+		add(RiskWildcardResources, SeverityHigh, "允许访问所有资源或所有 API 组 (resources/apiGroups: *)")
+	}
+	if len(rule.Resources) == 0 {
+		return findings
+	}
+	for _, verb := range []string{"get", "list", "watch"} {
+		if covers(rule, verb, "", "secrets", "") {
+			add(RiskSecretsRead, SeverityHigh, fmt.Sprintf("可以读取 Secret (%s)", verb))
+			break
+		}
+	}
+	for _, sub := range []string{"exec", "attach"} {
+		// exec 和 attach 通过 WebSocket 连接时鉴权的 verb 是 get
+		if covers(rule, "create", "", "pods", sub) || covers(rule, "get", "", "pods", sub) {
+			add(RiskPodExec, SeverityHigh, fmt.Sprintf("可以在容器中执行命令 (pods/%s)", sub))
+			break
+		}
+	}
+	for _, resource := range []string{"roles", "clusterroles"} {
+		if covers(rule, "escalate", rbacv1.GroupName, resource, "") {
+			add(RiskEscalate, SeverityCritical, fmt.Sprintf("可以创建或修改超过自身权限的角色 (escalate %s)", resource))
+			break
+		}
+	}
+	for _, resource := range []string{"roles", "clusterroles"} {
+		if covers(rule, "bind", rbacv1.GroupName, resource, "") {
+			add(RiskBind, SeverityCritical, fmt.Sprintf("可以绑定超过自身权限的角色 (bind %s)", resource))
+			break
+		}
+	}
+	for _, target := range []struct{ group, resource string }{{"", "users"}, {"", "groups"}, {"", "serviceaccounts"}, {"authentication.k8s.io", "userextras"}, {"authentication.k8s.io", "uids"}} {
+		if covers(rule, "impersonate", target.group, target.resource, "") {
+			add(RiskImpersonate, SeverityCritical, fmt.Sprintf("可以模拟其它身份 (impersonate %s)", target.resource))
+			break
+		}
+	}
+	for _, verb := range []string{"get", "create"} {
+		if covers(rule, verb, "", "nodes", "proxy") {
+			add(RiskNodesProxy, SeverityCritical, "可以通过 nodes/proxy 直接访问 kubelet API")
+			break
+		}
+	}
+	if namespace == AllNamespaces || namespace == "kube-system" {
+		for _, w := range workloadResources {
+			if covers(rule, "create", w.group, w.resource, "") {
+				add(RiskKubeSystemWorkload, SeverityCritical, fmt.Sprintf("可以在 kube-system 中创建工作负载 (%s)", w.resource))
+				break
+			}
+		}
+	}
+	return findings
+}
+
+// isSystemSubject 判断是否是集群组件自身使用的主体。system:authenticated、system:serviceaccounts
+// 等组包含所有用户或所有 ServiceAccount，绑定到这些组的权限正是审计最需要关注的，不算系统主体
+func isSystemSubject(subject rbacv1.Subject) bool {
+	if subject.Kind == rbacv1.ServiceAccountKind {
+		return subject.Namespace == "kube-system"
+	}
+	return strings.HasPrefix(subject.Name, "system:serviceaccount:kube-system:") ||
+		strings.HasPrefix(subject.Name, "system:node") ||
+		strings.HasPrefix(subject.Name, "system:kube-")
+}
+
+func isAnonymousSubject(subject rbacv1.Subject) bool {
+	return (subject.Kind == rbacv1.UserKind && subject.Name == "system:anonymous") ||
+		(subject.Kind == rbacv1.GroupKind && subject.Name == "system:unauthenticated")
+}
+
+// RiskScan 扫描所有绑定，按主体汇总危险权限并计算风险分数
+func (s *Snapshot) RiskScan(req RiskScanRequest) RiskReport {
+	subjects := make(map[string]*SubjectRisk)
+	seen := make(map[string]bool)
+	add := func(subject rbacv1.Subject, f Finding) {
+		key := SubjectKey(subject)
+		findingKey := strings.Join([]string{key, f.Type, f.Namespace, f.Binding.Kind, f.Binding.Namespace, f.Binding.Name, f.Message}, "|")
+		if seen[findingKey] {
+			return
+		}
+		seen[findingKey] = true
+		sr, ok := subjects[key]
+		if !ok {
+			sr = &SubjectRisk{Kind: subject.Kind, Name: subject.Name}
+			if subject.Kind == rbacv1.ServiceAccountKind {
+				sr.Namespace = subject.Namespace
+			}
+			subjects[key] = sr
+		}
+		sr.Findings = append(sr.Findings, f)
+	}
+
+	for _, b := range s.Bindings(func(subject rbacv1.Subject) bool {
+		return !req.ExcludeSystem || !isSystemSubject(subject) || isAnonymousSubject(subject)
+	}) {
+		namespace := b.Namespace
+		if namespace == "" {
+			namespace = AllNamespaces
+		}
+		for _, subject := range b.Subjects {
+			if isAnonymousSubject(subject) {
+				add(subject, Finding{
+					Type:      RiskAnonymousBinding,
+					Severity:  SeverityCritical,
+					Message:   fmt.Sprintf("未认证的请求通过 %s %s 获得了 %s %s 的权限", b.Binding.Kind, b.Binding.Name, b.Role.Kind, b.Role.Name),
+					Namespace: namespace,
+					Binding:   &b.Binding,
+					Role:      b.Role,
+				})
+			}
+			for _, rule := range b.Rules {
+				for _, f := range ruleFindings(rule.PolicyRule, namespace) {
+					f.Namespace = namespace
+					f.Binding = &b.Binding
+					f.Role = b.Role
+					f.Rule = &rule
+					add(subject, f)
+				}
+			}
+		}
+	}
+
+	report := RiskReport{GeneratedAt: time.Now(), Subjects: []SubjectRisk{}}
+	for _, sr := range subjects {
+		sr.Score, sr.Severity = scoreFindings(sr.Findings)
+		if sr.Score < req.MinScore {
+			continue
+		}
+		report.Subjects = append(report.Subjects, *sr)
+	}
+	sort.Slice(report.Subjects, func(i, j int) bool {
+		a, b := report.Subjects[i], report.Subjects[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return SubjectKey(rbacv1.Subject{Kind: a.Kind, Name: a.Name, Namespace: a.Namespace}) <
+			SubjectKey(rbacv1.Subject{Kind: b.Kind, Name: b.Name, Namespace: b.Namespace})
+	})
+	report.UnboundRoles = s.unboundRoleRisks(req)
+	return report
+}
+
+// scoreFindings 计算风险分数和最高风险等级，并把发现按风险等级从高到低排序。
+// 同一类型的风险在同一命名空间只计一次分，避免一个大角色的多条规则重复累加
+func scoreFindings(findings []Finding) (int, string) {
+	score, severity := 0, ""
+	counted := make(map[string]bool)
+	for _, f := range findings {
+		if severityScore[f.Severity] > severityScore[severity] {
+			severity = f.Severity
+		}
+		if key := f.Type + "|" + f.Namespace; !counted[key] {
+			counted[key] = true
+			score += severityScore[f.Severity]
+		}
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityScore[findings[i].Severity] > severityScore[findings[j].Severity]
+	})
+	return score, severity
+}
+
+// unboundRoleRisks 检查没有被绑定引用的 Role 和 ClusterRole。被已绑定的聚合 ClusterRole 聚合的 ClusterRole
+// 已经通过聚合授予了权限，不算未绑定
+func (s *Snapshot) unboundRoleRisks(req RiskScanRequest) []UnboundRoleRisk {
+	boundRoles := make(map[string]bool)
+	boundClusterRoles := make(map[string]bool)
+	var markClusterRole func(name string)
+	markClusterRole = func(name string) {
+		if boundClusterRoles[name] {
+			return
+		}
+		boundClusterRoles[name] = true
+		if cr, ok := s.ClusterRoles[name]; ok {
+			for _, other := range s.aggregatedClusterRoles(cr) {
+				markClusterRole(other)
+			}
+		}
+	}
+	for _, crb := range s.ClusterRoleBindings {
+		markClusterRole(crb.RoleRef.Name)
+	}
+	for _, rb := range s.RoleBindings {
+		if rb.RoleRef.Kind == "ClusterRole" {
+			markClusterRole(rb.RoleRef.Name)
+		} else {
+			boundRoles[roleKey(rb.Namespace, rb.RoleRef.Name)] = true
+		}
+	}
+
+	risks := []UnboundRoleRisk{}
+	check := func(role Ref, namespace string, rules []SourcedRule) {
+		var findings []Finding
+		for _, rule := range rules {
+			for _, f := range ruleFindings(rule.PolicyRule, namespace) {
+				f.Namespace = namespace
+				f.Role = role
+				f.Rule = &rule
+				findings = append(findings, f)
+			}
+		}
+		if len(findings) == 0 {
+			return
+		}
+		risk := UnboundRoleRisk{Role: role, Findings: findings}
+		risk.Score, risk.Severity = scoreFindings(findings)
+		if risk.Score >= req.MinScore {
+			risks = append(risks, risk)
+		}
+	}
+	for name := range s.ClusterRoles {
+		if boundClusterRoles[name] || (req.ExcludeSystem && strings.HasPrefix(name, "system:")) {
+			continue
+		}
+		// 未绑定的 ClusterRole 可能被 ClusterRoleBinding 绑定，按所有命名空间检查
+		check(Ref{Kind: "ClusterRole", Name: name}, AllNamespaces, s.ClusterRoleRules(name))
+	}
+	for key, role := range s.Roles {
+		if boundRoles[key] || (req.ExcludeSystem && role.Namespace == "kube-system") {
+			continue
+		}
+		var rules []SourcedRule
+		for _, rule := range role.Rules {
+			rules = append(rules, SourcedRule{PolicyRule: rule})
+		}
+		check(Ref{Kind: "Role", Name: role.Name, Namespace: role.Namespace}, role.Namespace, rules)
+	}
+	sort.Slice(risks, func(i, j int) bool {
+		a, b := risks[i], risks[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Role.Namespace != b.Role.Namespace {
+			return a.Role.Namespace < b.Role.Namespace
+		}
+		return a.Role.Name < b.Role.Name
+	})
+	return risks
+}
+
+// RiskScan 扫描所有 Role、ClusterRole 和绑定中的危险权限，返回按主体汇总的风险报告
+func RiskScan(w http.ResponseWriter, r *http.Request) {
+	var resp RiskScanResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req RiskScanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	snapshot, err := Load(r.Context())
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.RiskReport = snapshot.RiskScan(req)
+}
+
+// RiskExport 把风险报告导出为 JSON 或 CSV 文件，CSV 每行一个发现，未绑定角色的发现没有主体
+func RiskExport(w http.ResponseWriter, r *http.Request) {
+	var resp handlers.ErrorResponse
+	writeError := func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}
+
+	// 解析请求参数
+	var req RiskExportRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		writeError()
+		return
+	}
+	if req.Format == "" {
+		req.Format = "json"
+	}
+	if req.Format != "json" && req.Format != "csv" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("不支持的导出格式: %s", req.Format)
+		writeError()
+		return
+	}
+	snapshot, err := Load(r.Context())
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		writeError()
+		return
+	}
+	report := snapshot.RiskScan(req.RiskScanRequest)
+
+	var data []byte
+	contentType := "application/json"
+	if req.Format == "csv" {
+		data, err = reportCSV(report)
+		contentType = "text/csv; charset=utf-8"
+	} else {
+		data, err = json.MarshalIndent(report, "", "  ")
+	}
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("导出失败: %v", err)
+		writeError()
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=rbac-risk-%s.%s", report.GeneratedAt.Format("20060102150405"), req.Format))
+	w.Write(data)
+}
+
+func reportCSV(report RiskReport) ([]byte, error) {
+	var buf bytes.Buffer
+	// 加上 BOM，方便用 Excel 直接打开中文内容
+	buf.WriteString("\ufeff")
+	cw := csv.NewWriter(&buf)
+	cw.Write([]string{"subjectKind", "subjectNamespace", "subjectName", "score", "subjectSeverity",
+		"type", "severity", "namespace", "bindingKind", "binding", "roleKind", "role", "aggregatedFrom",
+		"verbs", "apiGroups", "resources", "resourceNames", "message"})
+	write := func(subject []string, f Finding) {
+		var bindingKind, binding string
+		if f.Binding != nil {
+			bindingKind, binding = f.Binding.Kind, f.Binding.Name
+			if f.Binding.Namespace != "" {
+				binding = f.Binding.Namespace + "/" + f.Binding.Name
+			}
+		}
+		var rule SourcedRule
+		if f.Rule != nil {
+			rule = *f.Rule
+		}
+		role := f.Role.Name
+		if f.Role.Namespace != "" {
+			role = f.Role.Namespace + "/" + f.Role.Name
+		}
+		cw.Write(append(subject,
+			f.Type, f.Severity, f.Namespace, bindingKind, binding, f.Role.Kind, role, rule.AggregatedFrom,
+			strings.Join(rule.Verbs, ";"), strings.Join(rule.APIGroups, ";"), strings.Join(rule.Resources, ";"),
+			strings.Join(rule.ResourceNames, ";"), f.Message))
+	}
+	for _, sr := range report.Subjects {
+		for _, f := range sr.Findings {
+			write([]string{sr.Kind, sr.Namespace, sr.Name, strconv.Itoa(sr.Score), sr.Severity}, f)
+		}
+	}
+	for _, ur := range report.UnboundRoles {
+		for _, f := range ur.Findings {
+			write([]string{"", "", "", strconv.Itoa(ur.Score), ur.Severity}, f)
+		}
+	}
+	cw.Flush()
+	return buf.Bytes(), cw.Error()
+}
@@ -0,0 +1,122 @@
+package access
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"net/http"
+	"sort"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+type WhoCanRequest struct {
+	Verb     string `json:"verb"`
+	Resource string `json:"resource"`
+	APIGroup string `json:"apiGroup"`
+	// 为空时只统计对所有命名空间生效的授权，即 ClusterRoleBinding
+	NameSpace    string `json:"namespace"`
+	ResourceName string `json:"resourceName"`
+	// 非资源 URL，例如 /metrics，设置后忽略 resource 和 apiGroup
+	NonResourceURL string `json:"nonResourceURL"`
+}
+
+type WhoCanResponse struct {
+	handlers.ErrorResponse
+	Subjects []AllowedSubject `json:"subjects"`
+}
+
+type AllowedSubject struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace,omitempty"`
+	// 授予这个权限的所有绑定链
+	Chains []Chain `json:"chains"`
+}
+
+// Chain 是主体 -> 绑定 -> 角色 -> 规则的授权链
+type Chain struct {
+	Binding Ref         `json:"binding"`
+	Role    Ref         `json:"role"`
+	Rule    SourcedRule `json:"rule"`
+}
+
+// WhoCan 反向查询有权限执行某个操作的所有 User、Group 和 ServiceAccount，以及授权链。
+// 资源可以写成 pods/exec 的形式表示子资源
+func WhoCan(w http.ResponseWriter, r *http.Request) {
+	var resp WhoCanResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req WhoCanRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.Verb == "" || (req.Resource == "" && req.NonResourceURL == "") {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "verb 和 resource 不能为空"
+		return
+	}
+
+	snapshot, err := Load(r.Context())
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resource, subresource, _ := strings.Cut(req.Resource, "/")
+	accessReq := Request{
+		Verb:           req.Verb,
+		APIGroup:       req.APIGroup,
+		Resource:       resource,
+		Subresource:    subresource,
+		Name:           req.ResourceName,
+		Namespace:      req.NameSpace,
+		NonResourceURL: req.NonResourceURL,
+	}
+	resp.Subjects = whoCan(snapshot.Grants(), accessReq)
+}
+
+func whoCan(grants []Grant, req Request) []AllowedSubject {
+	subjects := make(map[string]*AllowedSubject)
+	for _, g := range grants {
+		// 非资源 URL 只能通过 ClusterRoleBinding 授权
+		if req.NonResourceURL != "" && g.Namespace != "" {
+			continue
+		}
+		if !g.AppliesTo(req.Namespace) || !Allows(g.Rule.PolicyRule, req) {
+			continue
+		}
+		key := SubjectKey(g.Subject)
+		s, ok := subjects[key]
+		if !ok {
+			s = &AllowedSubject{Kind: g.Subject.Kind, Name: g.Subject.Name}
+			if g.Subject.Kind == rbacv1.ServiceAccountKind {
+				s.Namespace = g.Subject.Namespace
+			}
+			subjects[key] = s
+		}
+		s.Chains = append(s.Chains, Chain{Binding: g.Binding, Role: g.Role, Rule: g.Rule})
+	}
+
+	result := make([]AllowedSubject, 0, len(subjects))
+	for _, s := range subjects {
+		result = append(result, *s)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Kind != result[j].Kind {
+			return result[i].Kind < result[j].Kind
+		}
+		if result[i].Namespace != result[j].Namespace {
+			return result[i].Namespace < result[j].Namespace
+		}
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -9,15 +9,16 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListClusterRoleRequest struct {
+	handlers.ListQuery
 	ClusterRoleName string `json:"clusterRoleName"`
 }
 
 type ListClusterRoleResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	ClusterRoles []ClusterRole `json:"clusterRoles"`
 }
 
@@ -28,7 +29,6 @@ type ClusterRole struct {
 	Pods       string            `json:"pods"`
 	CreateTime string            `json:"createTime"`
 	Rules      []RuleInfo        `json:"rules"`
-	Yaml       string            `json:"yaml"`
 }
 type RuleInfo struct {
 	Verbs    []string `json:"verbs"`
@@ -52,7 +52,7 @@ func ListClusterRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.ClusterRoleName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.ClusterRoleName)
 	}
@@ -62,20 +62,26 @@ func ListClusterRole(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, clusterRole := range clusterRoles.Items {
-		yamlData, err := k8s.ResourceToYAML(&clusterRole)
-		if err != nil {
-			resp.ErrorCode = "500"
-			resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
-			return
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, clusterRoles.Items, clusterRoles.ListMeta, func(clusterRole *v1.ClusterRole) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         clusterRole.Name,
+			Namespace:    clusterRole.Namespace,
+			CreationTime: clusterRole.CreationTimestamp.Time,
 		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, clusterRole := range items {
 		resp.ClusterRoles = append(resp.ClusterRoles, ClusterRole{
 			Name:       clusterRole.Name,
 			Namespace:  clusterRole.Namespace,
 			Labels:     clusterRole.Labels,
 			CreateTime: clusterRole.CreationTimestamp.Format("2006-01-02 15:04:05"),
 			Rules:      returnRules(clusterRole),
-			Yaml:       string(yamlData),
 		})
 	}
 	return
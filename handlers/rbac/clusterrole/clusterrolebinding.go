@@ -9,15 +9,16 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListClusterRoleBindingRequest struct {
+	handlers.ListQuery
 	ClusterRoleBindingName string `json:"clusterRoleBindingName"`
 }
 
 type ListClusterRoleBindingResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	ClusterRoleBindings []ClusterRoleBinding `json:"clusterRoleBindings"`
 }
 
@@ -29,7 +30,6 @@ type ClusterRoleBinding struct {
 	CreateTime string            `json:"createTime"`
 	RoleRef    v1.RoleRef        `json:"roleRef"`
 	Subjects   []v1.Subject      `json:"subjects"`
-	Yaml       string            `json:"yaml"`
 }
 
 func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
@@ -48,7 +48,7 @@ func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.ClusterRoleBindingName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.ClusterRoleBindingName)
 	}
@@ -58,15 +58,20 @@ func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, clusterRoleBinding := range clusterRoleBindings.Items {
-		// 转换为 YAML
-		yamlData, err := k8s.ResourceToYAML(&clusterRoleBinding)
-		if err != nil {
-			resp.ErrorCode = "500"
-			resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
-			return
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, clusterRoleBindings.Items, clusterRoleBindings.ListMeta, func(clusterRoleBinding *v1.ClusterRoleBinding) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         clusterRoleBinding.Name,
+			Namespace:    clusterRoleBinding.Namespace,
+			CreationTime: clusterRoleBinding.CreationTimestamp.Time,
 		}
-
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, clusterRoleBinding := range items {
 		resp.ClusterRoleBindings = append(resp.ClusterRoleBindings, ClusterRoleBinding{
 			Name:       clusterRoleBinding.Name,
 			Namespace:  clusterRoleBinding.Namespace,
@@ -74,7 +79,6 @@ func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
 			CreateTime: clusterRoleBinding.CreationTimestamp.Format("2006-01-02 15:04:05"),
 			RoleRef:    clusterRoleBinding.RoleRef,
 			Subjects:   clusterRoleBinding.Subjects,
-			Yaml:       string(yamlData),
 		})
 	}
 	return
@@ -9,15 +9,16 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListClusterRoleBindingRequest struct {
+	handlers.ListQuery
 	ClusterRolebindingName  string `json:"clusterRolebindingName"`
 }
 
 type ListRoleResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	ClusterRolebindings []ClusterRoleBinding `json:"clusterRolebindings"`
 }
 
@@ -44,7 +45,7 @@ func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.ClusterRolebindingName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.ClusterRolebindingName)
 	}
@@ -54,7 +55,20 @@ func ListClusterRoleBinding(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, clusterRolebinding := range rolebindings.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, rolebindings.Items, rolebindings.ListMeta, func(clusterRolebinding *v1.ClusterRoleBinding) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         clusterRolebinding.Name,
+			Namespace:    clusterRolebinding.Namespace,
+			CreationTime: clusterRolebinding.CreationTimestamp.Time,
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, clusterRolebinding := range items {
 		resp.ClusterRolebindings = append(resp.ClusterRolebindings, ClusterRoleBinding{
 			Name:       clusterRolebinding.Name,
 			Labels:     clusterRolebinding.Labels,
@@ -9,16 +9,17 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListRoleRequest struct {
+	handlers.ListQuery
 	RoleName  string `json:"roleName"`
 	NameSpace string `json:"namespace"`
 }
 
 type ListRoleResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Roles []Role `json:"roles"`
 }
 
@@ -52,7 +53,7 @@ func ListRole(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.RoleName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.RoleName)
 	}
@@ -62,7 +63,20 @@ func ListRole(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, role := range roles.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, roles.Items, roles.ListMeta, func(role *v1.Role) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         role.Name,
+			Namespace:    role.Namespace,
+			CreationTime: role.CreationTimestamp.Time,
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, role := range items {
 		resp.Roles = append(resp.Roles, Role{
 			Name:       role.Name,
 			Namespace:  role.Namespace,
@@ -9,16 +9,17 @@ import (
 	"net/http"
 
 	v1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListRoleBindingRequest struct {
+	handlers.ListQuery
 	RoleBindingName  string `json:"roleBindingName"`
 	NameSpace string `json:"namespace"`
 }
 
 type ListRoleResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	RoleBindings []RoleBinding `json:"roleBindings"`
 }
 
@@ -46,7 +47,7 @@ func ListRoleBinding(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.RoleBindingName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.RoleBindingName)
 	}
@@ -56,7 +57,20 @@ func ListRoleBinding(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, roleBinding := range rolebindings.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, rolebindings.Items, rolebindings.ListMeta, func(roleBinding *v1.RoleBinding) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         roleBinding.Name,
+			Namespace:    roleBinding.Namespace,
+			CreationTime: roleBinding.CreationTimestamp.Time,
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, roleBinding := range items {
 		resp.RoleBindings = append(resp.RoleBindings, RoleBinding{
 			Name:       roleBinding.Name,
 			NameSpace:  roleBinding.Namespace,
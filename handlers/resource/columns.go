@@ -0,0 +1,150 @@
+package resource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+// printerColumn 是 CRD 中 additionalPrinterColumns 定义的一列
+type printerColumn struct {
+	Column
+	path *jsonpath.JSONPath
+}
+
+func (c printerColumn) value(obj *unstructured.Unstructured) interface{} {
+	results, err := c.path.FindResults(obj.Object)
+	if err != nil || len(results) == 0 || len(results[0]) == 0 {
+		return nil
+	}
+	if len(results[0]) == 1 {
+		return results[0][0].Interface()
+	}
+	var values []interface{}
+	for _, v := range results[0] {
+		values = append(values, v.Interface())
+	}
+	return values
+}
+
+// printerColumns 读取 CRD 对应版本的 additionalPrinterColumns，内置资源返回空
+func printerColumns(ctx context.Context, gvr GVR) ([]printerColumn, error) {
+	if gvr.Group == "" || !strings.Contains(gvr.Group, ".") {
+		return nil, nil
+	}
+	crd, err := k8s.GetDynamicClient().Resource(k8s.CRDResource).Get(ctx, gvr.Resource+"."+gvr.Group, metav1.GetOptions{})
+	if err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("获取 CRD 失败: %v", err)
+	}
+	versions, _, _ := unstructured.NestedSlice(crd.Object, "spec", "versions")
+	var columns []printerColumn
+	for _, v := range versions {
+		version, ok := v.(map[string]interface{})
+		if !ok || version["name"] != gvr.Version {
+			continue
+		}
+		defs, _, _ := unstructured.NestedSlice(version, "additionalPrinterColumns")
+		for _, d := range defs {
+			def, ok := d.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			c := printerColumn{}
+			c.Name, _, _ = unstructured.NestedString(def, "name")
+			c.Type, _, _ = unstructured.NestedString(def, "type")
+			c.Format, _, _ = unstructured.NestedString(def, "format")
+			c.Description, _, _ = unstructured.NestedString(def, "description")
+			priority, _, _ := unstructured.NestedInt64(def, "priority")
+			c.Priority = int32(priority)
+			path, _, _ := unstructured.NestedString(def, "jsonPath")
+
+			c.path = jsonpath.New(c.Name).AllowMissingKeys(true)
+			if err := c.path.Parse(fmt.Sprintf("{%s}", path)); err != nil {
+				return nil, fmt.Errorf("解析列 %s 的 jsonPath %s 失败: %v", c.Name, path, err)
+			}
+			columns = append(columns, c)
+		}
+	}
+	return columns, nil
+}
+
+type tableResult struct {
+	meta    handlers.ListMeta
+	columns []Column
+	items   []DynamicResource
+}
+
+// tableAccept 让 API Server 按 kubectl get 的格式返回列和行
+const tableAccept = "application/json;as=Table;v=v1;g=meta.k8s.io,application/json"
+
+// listTable 使用服务端 Table 格式列出资源，列定义和值由 API Server 计算，内置资源和 CRD 都适用。
+// 名称、状态过滤和排序需要完整对象，这种模式下只支持 API Server 自身的标签过滤和分页
+func listTable(ctx context.Context, gvr GVR, query handlers.ListQuery) (*tableResult, error) {
+	path := "/apis/" + gvr.Group + "/" + gvr.Version
+	if gvr.Group == "" {
+		path = "/api/" + gvr.Version
+	}
+	if gvr.NameSpace != "" {
+		path += "/namespaces/" + gvr.NameSpace
+	}
+	path += "/" + gvr.Resource
+
+	req := k8s.GetClient().CoreV1().RESTClient().Get().AbsPath(path).
+		SetHeader("Accept", tableAccept).
+		Param("includeObject", "Metadata")
+	if query.LabelSelector != "" {
+		req = req.Param("labelSelector", query.LabelSelector)
+	}
+	if query.Limit > 0 {
+		req = req.Param("limit", fmt.Sprintf("%d", query.Limit))
+	}
+	if query.Continue != "" {
+		req = req.Param("continue", query.Continue)
+	}
+	data, err := req.DoRaw(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var table metav1.Table
+	if err := json.Unmarshal(data, &table); err != nil {
+		return nil, fmt.Errorf("解析 Table 失败: %v", err)
+	}
+	if table.Kind != "Table" {
+		return nil, fmt.Errorf("该资源不支持 Table 格式")
+	}
+
+	result := &tableResult{meta: handlers.ServerSideListMeta(query, len(table.Rows), table.ListMeta)}
+	for _, c := range table.ColumnDefinitions {
+		result.columns = append(result.columns, Column{
+			Name:        c.Name,
+			Type:        c.Type,
+			Format:      c.Format,
+			Description: c.Description,
+			Priority:    c.Priority,
+		})
+	}
+	for _, row := range table.Rows {
+		item := DynamicResource{Cells: row.Cells}
+		var meta metav1.PartialObjectMetadata
+		if len(row.Object.Raw) > 0 && json.Unmarshal(row.Object.Raw, &meta) == nil {
+			item.Name = meta.Name
+			item.Namespace = meta.Namespace
+			item.Labels = meta.Labels
+			item.CreateTime = meta.CreationTimestamp.Format("2006-01-02 15:04:05")
+		}
+		result.items = append(result.items, item)
+	}
+	return result, nil
+}
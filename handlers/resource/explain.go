@@ -0,0 +1,174 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"sort"
+	"strings"
+
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+type ExplainRequest struct {
+	// 资源和字段路径，例如 deployment.spec.strategy、deploy.spec.template
+	Path string `json:"path"`
+	// 可选，例如 apps/v1，不填使用首选版本
+	APIVersion string `json:"apiVersion"`
+}
+
+type ExplainResponse struct {
+	handlers.ErrorResponse
+	Group   string       `json:"group"`
+	Version string       `json:"version"`
+	Kind    string       `json:"kind"`
+	Field   ExplainField `json:"field"`
+}
+
+type ExplainField struct {
+	Name        string        `json:"name"`
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Required    bool          `json:"required"`
+	Enum        []interface{} `json:"enum,omitempty"`
+	Default     interface{}   `json:"default,omitempty"`
+	// 下一级字段，用于编辑器补全
+	Fields []ExplainField `json:"fields,omitempty"`
+}
+
+// Explain 类似 kubectl explain，基于 /openapi/v3 返回资源或字段的说明、类型、必填项和枚举值
+func Explain(w http.ResponseWriter, r *http.Request) {
+	var resp ExplainResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	resource, fields := splitExplainPath(req.Path)
+	if resource == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "path 不能为空"
+		return
+	}
+	gvk, err := k8s.KindFor(resource, req.APIVersion)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.Group, resp.Version, resp.Kind = gvk.Group, gvk.Version, gvk.Kind
+
+	root, doc, err := k8s.SchemaForKind(gvk)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	field := ExplainField{Name: gvk.Kind, Type: schemaType(doc, root), Description: root.Description}
+	current := root
+	for i, name := range fields {
+		parent := explainTarget(doc, current)
+		prop, ok := parent.Properties[name]
+		if !ok {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = fmt.Sprintf("字段 %s 不存在", strings.Join(fields[:i+1], "."))
+			return
+		}
+		current = &prop
+		field = explainField(doc, name, current, parent)
+	}
+	field.Fields = explainChildren(doc, current)
+	resp.Field = field
+}
+
+// splitExplainPath 拆分资源名和字段路径，资源属于哪个 group 通过 apiVersion 指定
+func splitExplainPath(path string) (string, []string) {
+	parts := strings.Split(strings.Trim(path, "."), ".")
+	return parts[0], parts[1:]
+}
+
+// explainTarget 返回继续向下查找字段时使用的 schema，数组取元素的 schema
+func explainTarget(doc *spec3.OpenAPI, s *spec.Schema) *spec.Schema {
+	s = k8s.ResolveSchema(doc, s)
+	for s.Items != nil && s.Items.Schema != nil {
+		s = k8s.ResolveSchema(doc, s.Items.Schema)
+	}
+	return s
+}
+
+func explainField(doc *spec3.OpenAPI, name string, s *spec.Schema, parent *spec.Schema) ExplainField {
+	field := ExplainField{
+		Name:        name,
+		Type:        schemaType(doc, s),
+		Description: s.Description,
+		Default:     s.Default,
+		Enum:        s.Enum,
+	}
+	// 字段说明写在引用处，引用的 schema 上是类型的说明
+	resolved := k8s.ResolveSchema(doc, s)
+	if field.Description == "" {
+		field.Description = resolved.Description
+	}
+	if len(field.Enum) == 0 {
+		field.Enum = resolved.Enum
+	}
+	for _, r := range parent.Required {
+		if r == name {
+			field.Required = true
+		}
+	}
+	return field
+}
+
+func explainChildren(doc *spec3.OpenAPI, s *spec.Schema) []ExplainField {
+	target := explainTarget(doc, s)
+	var fields []ExplainField
+	for name := range target.Properties {
+		prop := target.Properties[name]
+		fields = append(fields, explainField(doc, name, &prop, target))
+	}
+	// 必填字段排在前面
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].Required != fields[j].Required {
+			return fields[i].Required
+		}
+		return fields[i].Name < fields[j].Name
+	})
+	return fields
+}
+
+// schemaType 按 kubectl explain 的格式返回类型，例如 []Container、map[string]string、Object
+func schemaType(doc *spec3.OpenAPI, s *spec.Schema) string {
+	ref := s.Ref.String()
+	if ref == "" && len(s.AllOf) == 1 {
+		ref = s.AllOf[0].Ref.String()
+	}
+	if ref != "" {
+		name := k8s.SchemaRefName(ref)
+		return name[strings.LastIndex(name, ".")+1:]
+	}
+	if v, _ := s.Extensions["x-kubernetes-int-or-string"].(bool); v {
+		return "IntOrString"
+	}
+	switch {
+	case s.Type.Contains("array") && s.Items != nil && s.Items.Schema != nil:
+		return "[]" + schemaType(doc, s.Items.Schema)
+	case s.Type.Contains("object") && s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil:
+		return "map[string]" + schemaType(doc, s.AdditionalProperties.Schema)
+	case len(s.Type) > 0 && !s.Type.Contains("object"):
+		return s.Type[0]
+	}
+	return "Object"
+}
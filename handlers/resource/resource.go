@@ -0,0 +1,326 @@
+package resource
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"net/http"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// GVR 通过 group、version、resource 指定任意资源类型，包括 CRD
+type GVR struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Resource  string `json:"resource"`
+	NameSpace string `json:"namespace"`
+}
+
+func (g GVR) groupVersionResource() schema.GroupVersionResource {
+	return schema.GroupVersionResource{Group: g.Group, Version: g.Version, Resource: g.Resource}
+}
+
+func (g GVR) validate() error {
+	if g.Version == "" || g.Resource == "" {
+		return fmt.Errorf("version 和 resource 不能为空")
+	}
+	return nil
+}
+
+// resourceInterface 命名空间为空时对集群级资源操作，或者列出所有命名空间的资源
+func (g GVR) resourceInterface() dynamic.ResourceInterface {
+	ri := k8s.GetDynamicClient().Resource(g.groupVersionResource())
+	if g.NameSpace != "" {
+		return ri.Namespace(g.NameSpace)
+	}
+	return ri
+}
+
+type ListDynamicResourceRequest struct {
+	handlers.ListQuery
+	GVR
+	// 为 table 时使用 API Server 的 Table 格式返回列，否则使用 CRD 的 additionalPrinterColumns
+	Format string `json:"format"`
+}
+
+// validate 通用资源没有统一的状态和重启次数，不支持按状态过滤和按重启次数排序；
+// table 格式直接由 API Server 分页，不支持名称过滤和排序
+func (req ListDynamicResourceRequest) validate() error {
+	if err := req.GVR.validate(); err != nil {
+		return err
+	}
+	if len(req.Status) > 0 {
+		return fmt.Errorf("通用资源列表不支持 status 过滤")
+	}
+	if req.SortBy == "restarts" {
+		return fmt.Errorf("通用资源列表不支持按 restarts 排序")
+	}
+	if req.Format == "table" && (req.Search != "" || req.SortBy != "") {
+		return fmt.Errorf("table 格式不支持 search 和 sortBy")
+	}
+	return nil
+}
+
+type ListDynamicResourceResponse struct {
+	handlers.ErrorResponse
+	handlers.ListMeta
+	Columns []Column          `json:"columns"`
+	Items   []DynamicResource `json:"items"`
+}
+
+type Column struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Format      string `json:"format"`
+	Description string `json:"description"`
+	Priority    int32  `json:"priority"`
+}
+
+type DynamicResource struct {
+	Name       string            `json:"name"`
+	Namespace  string            `json:"namespace"`
+	Labels     map[string]string `json:"labels"`
+	CreateTime string            `json:"createTime"`
+	// 与 columns 一一对应的值
+	Cells []interface{} `json:"cells"`
+}
+
+// ListDynamicResource 按 GVR 列出任意资源，支持分页、过滤和列投影
+func ListDynamicResource(w http.ResponseWriter, r *http.Request) {
+	var resp ListDynamicResourceResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ListDynamicResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	if req.Format == "table" {
+		table, err := listTable(r.Context(), req.GVR, req.ListQuery)
+		if err != nil {
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = fmt.Sprintf("获取资源列表失败: %v", err)
+			return
+		}
+		resp.ListMeta, resp.Columns, resp.Items = table.meta, table.columns, table.items
+		return
+	}
+
+	list, err := req.resourceInterface().List(r.Context(), req.ListOptions())
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取资源列表失败: %v", err)
+		return
+	}
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, list.Items, metav1.ListMeta{
+		Continue:           list.GetContinue(),
+		RemainingItemCount: list.GetRemainingItemCount(),
+	}, func(obj *unstructured.Unstructured) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         obj.GetName(),
+			Namespace:    obj.GetNamespace(),
+			CreationTime: obj.GetCreationTimestamp().Time,
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+
+	// 非 CRD 或者 CRD 没有定义 additionalPrinterColumns 时不返回额外的列
+	columns, err := printerColumns(r.Context(), req.GVR)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	for _, c := range columns {
+		resp.Columns = append(resp.Columns, c.Column)
+	}
+	for i := range items {
+		obj := &items[i]
+		item := DynamicResource{
+			Name:       obj.GetName(),
+			Namespace:  obj.GetNamespace(),
+			Labels:     obj.GetLabels(),
+			CreateTime: obj.GetCreationTimestamp().Format("2006-01-02 15:04:05"),
+		}
+		for _, c := range columns {
+			item.Cells = append(item.Cells, c.value(obj))
+		}
+		resp.Items = append(resp.Items, item)
+	}
+}
+
+type GetDynamicResourceRequest struct {
+	GVR
+	Name string `json:"name"`
+}
+
+type GetDynamicResourceResponse struct {
+	handlers.ErrorResponse
+	Object map[string]interface{} `json:"object"`
+}
+
+func GetDynamicResource(w http.ResponseWriter, r *http.Request) {
+	var resp GetDynamicResourceResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req GetDynamicResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	obj, err := req.resourceInterface().Get(r.Context(), req.Name, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("获取资源失败: %v", err)
+		return
+	}
+	obj.SetManagedFields(nil)
+	resp.Object = obj.Object
+}
+
+type DeleteDynamicResourceRequest struct {
+	GVR
+	Name string `json:"name"`
+	// Foreground、Background 或 Orphan，不填使用资源的默认策略
+	PropagationPolicy string `json:"propagationPolicy"`
+	DryRun            bool   `json:"dryRun"`
+}
+
+type DeleteDynamicResourceResponse struct {
+	handlers.ErrorResponse
+}
+
+func DeleteDynamicResource(w http.ResponseWriter, r *http.Request) {
+	var resp DeleteDynamicResourceResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DeleteDynamicResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	opt := metav1.DeleteOptions{}
+	switch metav1.DeletionPropagation(req.PropagationPolicy) {
+	case "":
+	case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+		policy := metav1.DeletionPropagation(req.PropagationPolicy)
+		opt.PropagationPolicy = &policy
+	default:
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("无效的 propagationPolicy: %s", req.PropagationPolicy)
+		return
+	}
+	if req.DryRun {
+		opt.DryRun = []string{metav1.DryRunAll}
+	}
+	if err := req.resourceInterface().Delete(r.Context(), req.Name, opt); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("删除资源失败: %v", err)
+		return
+	}
+}
+
+type PatchDynamicResourceRequest struct {
+	GVR
+	Name string `json:"name"`
+	// merge、json 或 strategic，CRD 不支持 strategic
+	PatchType string `json:"patchType"`
+	Patch     string `json:"patch"`
+	DryRun    bool   `json:"dryRun"`
+}
+
+type PatchDynamicResourceResponse struct {
+	handlers.ErrorResponse
+	Object map[string]interface{} `json:"object"`
+}
+
+var patchTypes = map[string]types.PatchType{
+	"":          types.MergePatchType,
+	"merge":     types.MergePatchType,
+	"json":      types.JSONPatchType,
+	"strategic": types.StrategicMergePatchType,
+}
+
+func PatchDynamicResource(w http.ResponseWriter, r *http.Request) {
+	var resp PatchDynamicResourceResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req PatchDynamicResourceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := req.validate(); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	patchType, ok := patchTypes[req.PatchType]
+	if !ok {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("不支持的 patchType: %s", req.PatchType)
+		return
+	}
+
+	opt := metav1.PatchOptions{}
+	if req.DryRun {
+		opt.DryRun = []string{metav1.DryRunAll}
+	}
+	obj, err := req.resourceInterface().Patch(r.Context(), req.Name, patchType, []byte(req.Patch), opt)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("更新资源失败: %v", err)
+		return
+	}
+	obj.SetManagedFields(nil)
+	resp.Object = obj.Object
+}
@@ -5,8 +5,12 @@ import (
 	"fmt"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"strings"
 
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
 	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/dynamic"
 )
 
 // 定义资源类型结构体
@@ -95,12 +99,90 @@ func GetResources(w http.ResponseWriter, r *http.Request) {
 
 
 
+// DiscoveredResource 是 discovery 返回的一种资源，只保留每个 group 的首选版本
+type DiscoveredResource struct {
+	schema.GroupVersionResource
+	Kind       string   `json:"kind"`
+	Namespaced bool     `json:"namespaced"`
+	Verbs      []string `json:"verbs"`
+}
+
+// HasVerbs 判断资源是否支持所有给定的操作
+func (d DiscoveredResource) HasVerbs(verbs ...string) bool {
+	for _, verb := range verbs {
+		found := false
+		for _, v := range d.Verbs {
+			if v == verb {
+				found = true
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// DiscoverResources 和 GetResources 一样通过 discovery 获取集群支持的资源，跳过子资源，
+// 某些 group 获取失败时返回其它 group 的资源
+func DiscoverResources() ([]DiscoveredResource, error) {
+	groups, apiResourceLists, err := k8s.GetDiscoveryClient().ServerGroupsAndResources()
+	if err != nil && !discovery.IsGroupDiscoveryFailedError(err) {
+		return nil, fmt.Errorf("获取资源列表失败: %v", err)
+	}
+	preferred := make(map[string]bool)
+	for _, group := range groups {
+		preferred[group.PreferredVersion.GroupVersion] = true
+	}
+	var resources []DiscoveredResource
+	for _, apiResourceList := range apiResourceLists {
+		if !preferred[apiResourceList.GroupVersion] {
+			continue
+		}
+		gv, err := schema.ParseGroupVersion(apiResourceList.GroupVersion)
+		if err != nil {
+			continue
+		}
+		for _, apiResource := range apiResourceList.APIResources {
+			if strings.Contains(apiResource.Name, "/") {
+				continue
+			}
+			resources = append(resources, DiscoveredResource{
+				GroupVersionResource: gv.WithResource(apiResource.Name),
+				Kind:                 apiResource.Kind,
+				Namespaced:           apiResource.Namespaced,
+				Verbs:                apiResource.Verbs,
+			})
+		}
+	}
+	return resources, nil
+}
+
 type YamlApplyRequest struct {
 	Yaml      string `json:"yaml"`
+	// 只做 schema 校验和服务端 dry-run，返回每个对象的 diff，不修改集群
+	Preview bool `json:"preview"`
+	// apply（服务端 apply，默认）或 merge（创建或 JSON merge patch）
+	Strategy string `json:"strategy"`
+	// 服务端 apply 的 field manager，不填时按请求头 X-Remote-User 生成
+	FieldManager string `json:"fieldManager"`
+	// 服务端 apply 冲突时强制接管字段
+	Force bool `json:"force"`
+	// apply set 的 ID，同一组对象使用相同的 ID
+	ApplySet string `json:"applySet"`
+	// 删除属于 applySet 但已从 YAML 中移除的对象，只在 YAML 中出现的资源类型和命名空间中查找，建议先用 preview 查看将被删除的对象
+	Prune bool `json:"prune"`
+	// prune 时同时删除 YAML 中出现的集群级类型（例如 Namespace、ClusterRole）的对象
+	PruneClusterScoped bool `json:"pruneClusterScoped"`
+	// 不为空时把 yaml 作为基础对象，先生成 overlay 后的对象再 apply
+	Overlay *k8s.Overlay `json:"overlay"`
 }
 
 type YamlApplyResponse struct {
 	ErrorResponse	
+	// 使用 overlay 时返回实际 apply 的 YAML
+	Yaml    string            `json:"yaml,omitempty"`
+	Results []k8s.ApplyResult `json:"results,omitempty"`
 }
 
 func YamlApply(w http.ResponseWriter, r *http.Request) {
@@ -117,21 +199,210 @@ func YamlApply(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// 先尝试 dry-run 检查资源是否存在
-	err := k8s.YamlOperationDryRunClient.Get(req.Yaml)
-	if err != nil {
-		// 资源不存在，使用 Apply 创建
-		if err := k8s.YamlOperationClient.Create(req.Yaml); err != nil {
+	if req.Overlay != nil {
+		yamlData, err := k8s.YamlOperationDryRunClient.BuildOverlay(req.Yaml, *req.Overlay)
+		if err != nil {
 			resp.ErrorCode = "400"
-			resp.ErrorMessage = fmt.Sprintf("创建资源失败: %v", err)
+			resp.ErrorMessage = fmt.Sprintf("生成 overlay 失败: %v", err)
 			return
 		}
+		req.Yaml = yamlData
+		resp.Yaml = yamlData
+	}
+
+	opt := k8s.ApplyOptions{
+		FieldManager:       FieldManager(r, req.FieldManager),
+		Force:              req.Force,
+		Strategy:           req.Strategy,
+		ApplySet:           req.ApplySet,
+		Prune:              req.Prune,
+		PruneClusterScoped: req.PruneClusterScoped,
+	}
+	var (
+		results []k8s.ApplyResult
+		err     error
+	)
+	if req.Preview {
+		results, err = k8s.YamlOperationDryRunClient.Preview(req.Yaml, opt)
 	} else {
-		// 资源存在，使用 Patch 更新
-		if err := k8s.YamlOperationClient.Patch(req.Yaml); err != nil {
-			resp.ErrorCode = "400"
-			resp.ErrorMessage = fmt.Sprintf("更新资源失败: %v", err)
-			return
+		results, err = k8s.YamlOperationClient.ApplyAll(req.Yaml, opt)
+	}
+	// prune 被跳过时仍然返回已经 apply 的结果
+	resp.Results = results
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("应用资源失败: %v", err)
+		return
+	}
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
 		}
 	}
-}
\ No newline at end of file
+	if failed > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("%d 个对象应用失败", failed)
+	}
+}
+
+// FieldManager 返回服务端 apply 使用的 field manager，没有指定时按用户区分，
+// 这样不同用户修改同一字段时能看到冲突。
+// 用户取自请求头 X-Remote-User，服务本身不做认证，直接信任这个请求头，
+// 只用于区分字段的拥有者，不能作为权限依据；需要可信的用户时应由前面的认证代理覆盖这个请求头
+func FieldManager(r *http.Request, fieldManager string) string {
+	if fieldManager != "" {
+		return fieldManager
+	}
+	if user := r.Header.Get("X-Remote-User"); user != "" {
+		return k8s.DefaultFieldManager + "-" + user
+	}
+	return k8s.DefaultFieldManager
+}
+
+type YamlBuildRequest struct {
+	Yaml    string      `json:"yaml"`
+	Overlay k8s.Overlay `json:"overlay"`
+}
+
+type YamlBuildResponse struct {
+	ErrorResponse
+	Yaml string `json:"yaml"`
+}
+
+// YamlBuild 只生成 overlay 后的 YAML，不修改集群
+func YamlBuild(w http.ResponseWriter, r *http.Request) {
+	var resp YamlBuildResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	var req YamlBuildRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	yamlData, err := k8s.YamlOperationDryRunClient.BuildOverlay(req.Yaml, req.Overlay)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("生成 overlay 失败: %v", err)
+		return
+	}
+	resp.Yaml = yamlData
+}
+
+type YamlDeleteRequest struct {
+	Yaml   string `json:"yaml"`
+	DryRun bool   `json:"dryRun"`
+	// Foreground、Background 或 Orphan，不填使用资源的默认策略
+	PropagationPolicy string `json:"propagationPolicy"`
+}
+
+type YamlDeleteResponse struct {
+	ErrorResponse
+	Results []k8s.ApplyResult `json:"results,omitempty"`
+}
+
+// YamlDelete 删除 YAML 中的对象，按依赖关系的逆序删除，Namespace 和 CRD 最后删除
+func YamlDelete(w http.ResponseWriter, r *http.Request) {
+	var resp YamlDeleteResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	var req YamlDeleteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	policy := metav1.DeletionPropagation(req.PropagationPolicy)
+	switch policy {
+	case "", metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+	default:
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("无效的 propagationPolicy: %s", req.PropagationPolicy)
+		return
+	}
+
+	client := k8s.YamlOperationClient
+	if req.DryRun {
+		client = k8s.YamlOperationDryRunClient
+	}
+	results, err := client.DeleteAll(req.Yaml, policy)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("删除资源失败: %v", err)
+		return
+	}
+	resp.Results = results
+	failed := 0
+	for _, result := range results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("%d 个对象删除失败", failed)
+	}
+}
+
+type GetResourceYamlResponse struct {
+	ErrorResponse
+	Yaml string `json:"yaml"`
+}
+
+// GetResourceYaml 通过 dynamic client 按 GVR、命名空间和名称获取任意对象的 YAML。
+// query 参数: group、version、resource、namespace、name；
+// clean=true 时去掉 managedFields、resourceVersion 和 status，也可以通过
+// stripManagedFields、stripResourceVersion、stripStatus 分别指定
+func GetResourceYaml(w http.ResponseWriter, r *http.Request) {
+	var resp GetResourceYamlResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	params := r.URL.Query()
+	gvr := schema.GroupVersionResource{
+		Group:    params.Get("group"),
+		Version:  params.Get("version"),
+		Resource: params.Get("resource"),
+	}
+	namespace := params.Get("namespace")
+	name := params.Get("name")
+	if gvr.Version == "" || gvr.Resource == "" || name == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "version、resource 和 name 不能为空"
+		return
+	}
+
+	var ri dynamic.ResourceInterface = k8s.GetDynamicClient().Resource(gvr)
+	if namespace != "" {
+		ri = k8s.GetDynamicClient().Resource(gvr).Namespace(namespace)
+	}
+	obj, err := ri.Get(r.Context(), name, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("获取资源失败: %v", err)
+		return
+	}
+
+	clean := params.Get("clean") == "true"
+	k8s.CleanObject(obj, k8s.CleanOptions{
+		ManagedFields:   clean || params.Get("stripManagedFields") == "true",
+		ResourceVersion: clean || params.Get("stripResourceVersion") == "true",
+		Status:          clean || params.Get("stripStatus") == "true",
+	})
+	yamlData, err := k8s.ResourceToYAML(obj.Object)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
+		return
+	}
+	resp.Yaml = yamlData
+}
@@ -5,10 +5,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"k8s-manage-api/handlers"
+	"k8s-manage-api/handlers/rbac/access"
 	"k8s-manage-api/k8s"
 	"net/http"
 	"time"
 
+	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -25,10 +27,13 @@ type ServiceAccountInfo struct {
 	// ClusterBindings []rbacv1.ClusterRoleBinding `json:"clusterBindings"`
 	Roles        []Role        `json:"roles"`
 	ClusterRoles []ClusterRole `json:"clusterRoles"`
+	// 合并所有绑定后的有效权限
+	Permissions access.Permissions `json:"permissions"`
 }
 
 type Role struct {
 	RoleName        string     `json:"roleName"`
+	RoleKind        string     `json:"roleKind"` // Role 或 ClusterRole，RoleBinding 可以引用 ClusterRole
 	NameSpace       string     `json:"nameSpace"`
 	RoleAge         string     `json:"roleAge"`
 	RoleBindingName string     `json:"roleBindingName"`
@@ -46,17 +51,23 @@ type ClusterRole struct {
 }
 
 type RuleInfo struct {
-	Verbs    []string `json:"verbs"`
-	ApiGroup []string `json:"apiGroup"`
-	Resource string   `json:"resource"`
+	Verbs          []string `json:"verbs"`
+	ApiGroup       []string `json:"apiGroup"`
+	Resource       string   `json:"resource"`
+	ResourceNames  []string `json:"resourceNames,omitempty"`
+	NonResourceURL string   `json:"nonResourceURL,omitempty"`
+	// 来自聚合 ClusterRole 中被聚合的 ClusterRole
+	AggregatedFrom string `json:"aggregatedFrom,omitempty"`
 }
 
 type ListServiceAccountsRequest struct {
+	handlers.ListQuery
 	Namespace string `json:"namespace"` //不填返回所有的namespace 的sa
 }
 
 type ListServiceAccountsResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	ServiceAccounts []ServiceAccount `json:"serviceAccounts"`
 }
 
@@ -97,14 +108,27 @@ func ListServiceAccounts(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	// 获取当前命名空间下的所有 ServiceAccount
-	sas, err := clientset.CoreV1().ServiceAccounts(ns.Name).List(context.TODO(), metav1.ListOptions{})
+	sas, err := clientset.CoreV1().ServiceAccounts(ns.Name).List(context.TODO(), req.ListOptions())
 	if err != nil {
 		resp.ErrorCode = "400"
 		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
 		return
 	}
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, sas.Items, sas.ListMeta, func(sa *corev1.ServiceAccount) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         sa.Name,
+			Namespace:    sa.Namespace,
+			CreationTime: sa.CreationTimestamp.Time,
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
 	// 遍历 ServiceAccount
-	for _, sa := range sas.Items {
+	for _, sa := range items {
 		resp.ServiceAccounts = append(resp.ServiceAccounts, ServiceAccount{
 			Name:      sa.Name,
 			Namespace: sa.Namespace,
@@ -187,129 +211,64 @@ func GetServiceAccountDetails(w http.ResponseWriter, r *http.Request) {
 	// 		saInfo.Secrets = append(saInfo.Secrets, *secret)
 	// 	}
 	// }
-	var roles = make(map[string]Role)
-	var clusterRoles = make(map[string]ClusterRole)
-	// 获取 RoleBinding
-	roleBindings, err := clientset.RbacV1().RoleBindings(req.Namespace).List(context.TODO(), metav1.ListOptions{})
-	if err == nil {
-		for _, rb := range roleBindings.Items {
-			for _, subject := range rb.Subjects {
-				if subject.Kind == "ServiceAccount" && subject.Name == req.ServiceAccountName && subject.Namespace == req.Namespace {
-					// resp.ServiceAccountInfo.RoleBindings = append(resp.ServiceAccountInfo.RoleBindings, rb)
 
-					// 获取关联的 Role
-					role, err := clientset.RbacV1().Roles(req.Namespace).Get(context.TODO(), rb.RoleRef.Name, metav1.GetOptions{})
-					if err != nil {
-						resp.ErrorCode = "400"
-						resp.ErrorMessage = fmt.Sprintf("获取service account role failed: %v", err)
-						return
-					}
-					if _, ok := roles[role.Name+role.Namespace]; !ok {
-						ro := Role{
-							RoleName:        role.Name,
-							RoleAge:         role.CreationTimestamp.String(),
-							NameSpace:       subject.Namespace,
-							RoleBindingName: rb.Name,
-							RoleBindingAge:  rb.CreationTimestamp.String(),
-							Rules:           []RuleInfo{},
-						}
-						for _, rule := range role.Rules {
-							for _, ruleSource := range rule.Resources {
-								ro.Rules = append(ro.Rules, RuleInfo{
-									Verbs:    rule.Verbs,
-									ApiGroup: rule.APIGroups,
-									Resource: ruleSource,
-								})
-							}
-						}
-						roles[role.Name+role.Namespace] = ro
-					} else {
-						for _, rule := range role.Rules {
-							for _, ruleSource := range rule.Resources {
-								if len(rule.Verbs) > 0 {
-									// 获取当前角色
-									roleTemp := roles[role.Name+role.Namespace]
-									// 添加新的规则
-									roleTemp.Rules = append(roleTemp.Rules, RuleInfo{
-										Verbs:    rule.Verbs,
-										ApiGroup: rule.APIGroups,
-										Resource: ruleSource,
-									})
-									// 更新 map 中的角色
-									roles[role.Name+role.Namespace] = roleTemp
-								}
-							}
-						}
-					}
-
-				}
-			}
+	// 除了直接绑定到 ServiceAccount 的权限，还包括绑定到 system:serviceaccounts 等组的权限
+	snapshot, err := access.Load(r.Context())
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	match := access.ServiceAccountMatcher(sa.Namespace, sa.Name)
+	for _, b := range snapshot.Bindings(match) {
+		rules := returnRules(b.Rules)
+		if b.Binding.Kind == "ClusterRoleBinding" {
+			resp.ServiceAccountInfo.ClusterRoles = append(resp.ServiceAccountInfo.ClusterRoles, ClusterRole{
+				ClusterRoleName:        b.Role.Name,
+				NameSpace:              sa.Namespace,
+				ClusterRoleAge:         b.RoleCreated.String(),
+				ClusterRoleBindingName: b.Binding.Name,
+				ClusterRoleBindingAge:  b.BindingCreated.String(),
+				Rules:                  rules,
+			})
+			continue
 		}
+		resp.ServiceAccountInfo.Roles = append(resp.ServiceAccountInfo.Roles, Role{
+			RoleName:        b.Role.Name,
+			RoleKind:        b.Role.Kind,
+			NameSpace:       b.Binding.Namespace,
+			RoleAge:         b.RoleCreated.String(),
+			RoleBindingName: b.Binding.Name,
+			RoleBindingAge:  b.BindingCreated.String(),
+			Rules:           rules,
+		})
 	}
+	resp.ServiceAccountInfo.Permissions = snapshot.EffectivePermissions(match)
+	return
+}
 
-	// 获取 ClusterRoleBinding
-	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(context.TODO(), metav1.ListOptions{})
-	if err == nil {
-		for _, crb := range clusterRoleBindings.Items {
-			for _, subject := range crb.Subjects {
-				if subject.Kind == "ServiceAccount" && subject.Name == req.ServiceAccountName && subject.Namespace == req.Namespace {
-					// resp.ServiceAccountInfo.ClusterBindings = append(resp.ServiceAccountInfo.ClusterBindings, crb)
-
-					// // 获取关联的 ClusterRole
-					clusterRole, err := clientset.RbacV1().ClusterRoles().Get(context.TODO(), crb.RoleRef.Name, metav1.GetOptions{})
-					if err != nil {
-						resp.ErrorCode = "400"
-						resp.ErrorMessage = fmt.Sprintf("获取service account clustr role failed: %v", err)
-						return
-					}
-					if _, ok := clusterRoles[clusterRole.Name+clusterRole.Namespace]; !ok {
-						clusterRo := ClusterRole{
-							ClusterRoleName:        clusterRole.Name,
-							ClusterRoleAge:         clusterRole.CreationTimestamp.String(),
-							NameSpace:              subject.Namespace,
-							ClusterRoleBindingName: crb.Name,
-							ClusterRoleBindingAge:  crb.CreationTimestamp.String(),
-							Rules:                  []RuleInfo{},
-						}
-						for _, rule := range clusterRole.Rules {
-							for _, ruleSource := range rule.Resources {
-								clusterRo.Rules = append(clusterRo.Rules, RuleInfo{
-									Verbs:    rule.Verbs,
-									ApiGroup: rule.APIGroups,
-									Resource: ruleSource,
-								})
-							}
-						}
-						clusterRoles[clusterRole.Name+clusterRole.Namespace] = clusterRo
-					} else {
-						for _, rule := range clusterRole.Rules {
-							for _, ruleSource := range rule.Resources {
-								if len(rule.Verbs) > 0 {
-									// 获取当前角色
-									clusterRoleTemp := clusterRoles[clusterRole.Name+clusterRole.Namespace]
-									// 添加新的规则
-									clusterRoleTemp.Rules = append(clusterRoleTemp.Rules, RuleInfo{
-										Verbs:    rule.Verbs,
-										ApiGroup: rule.APIGroups,
-										Resource: ruleSource,
-									})
-									// 更新 map 中的角色
-									clusterRoles[clusterRole.Name+clusterRole.Namespace] = clusterRoleTemp
-								}
-							}
-						}
-					}
-				}
-			}
+// returnRules 把规则展开成每个资源一行，非资源 URL 每个 URL 一行
+func returnRules(sourced []access.SourcedRule) []RuleInfo {
+	rules := []RuleInfo{}
+	for _, rule := range sourced {
+		for _, resource := range rule.Resources {
+			rules = append(rules, RuleInfo{
+				Verbs:          rule.Verbs,
+				ApiGroup:       rule.APIGroups,
+				Resource:       resource,
+				ResourceNames:  rule.ResourceNames,
+				AggregatedFrom: rule.AggregatedFrom,
+			})
+		}
+		for _, url := range rule.NonResourceURLs {
+			rules = append(rules, RuleInfo{
+				Verbs:          rule.Verbs,
+				NonResourceURL: url,
+				AggregatedFrom: rule.AggregatedFrom,
+			})
 		}
 	}
-	for _, v := range roles {
-		resp.ServiceAccountInfo.Roles = append(resp.ServiceAccountInfo.Roles, v)
-	}
-	for _, v := range clusterRoles {
-		resp.ServiceAccountInfo.ClusterRoles = append(resp.ServiceAccountInfo.ClusterRoles, v)
-	}
-	return
+	return rules
 }
 
 //
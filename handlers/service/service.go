@@ -8,16 +8,18 @@ import (
 	"k8s-manage-api/k8s"
 	"net/http"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	corev1 "k8s.io/api/core/v1"
 )
 
 type ListServiceRequest struct {
+	handlers.ListQuery
 	ServiceName string `json:"serviceName"`
 	NameSpace   string `json:"namespace"`
 }
 
 type ListServiceResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Services []Service `json:"services"`
 }
 
@@ -32,7 +34,6 @@ type Service struct {
 	ExternalEndpoints []string `json:"externalEndpoints"`
 	// 内部端点列表
 	InternalEndpoints []string `json:"internalEndpoints"`
-	Yaml string `json:"yaml"`
 	CreateTime string `json:"createTime"`
 }
 
@@ -52,7 +53,7 @@ func ListService(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.ServiceName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.ServiceName)
 	}
@@ -62,15 +63,21 @@ func ListService(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
-		// 转换为 YAML
-		yamlData, err := k8s.ResourceToYAML(&svc)
-		if err != nil {
-			resp.ErrorCode = "500"
-			resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
-			return
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *corev1.Service) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       string(svc.Spec.Type),
 		}
-
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Services = append(resp.Services, Service{
 			Name:              svc.Name,
 			Namespace:         svc.Namespace,
@@ -86,7 +93,6 @@ func ListService(w http.ResponseWriter, r *http.Request) {
 				}
 				return eps
 			}(),
-			Yaml:       string(yamlData),
 			CreateTime: svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
 		})
 	}
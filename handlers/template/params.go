@@ -0,0 +1,255 @@
+package template
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+// 参数类型
+const (
+	// 单行字符串，不能包含换行和控制字符
+	ParamString = "string"
+	// DNS-1123 label，用于对象名称
+	ParamName  = "name"
+	ParamImage = "image"
+	ParamInt   = "int"
+	ParamBool  = "bool"
+	// 端口列表，例如 [80, 443]
+	ParamPorts = "ports"
+	// 环境变量，例如 {"LOG_LEVEL": "debug"}
+	ParamEnv = "env"
+)
+
+var paramTypes = map[string]bool{
+	ParamString: true,
+	ParamName:   true,
+	ParamImage:  true,
+	ParamInt:    true,
+	ParamBool:   true,
+	ParamPorts:  true,
+	ParamEnv:    true,
+}
+
+// imageReference 粗略校验镜像地址，例如 nginx、registry:5000/app/web:v1、app@sha256:...
+var imageReference = regexp.MustCompile(`^[a-z0-9]+([._\-/:a-z0-9]*[a-z0-9])?(:[\w][\w.\-]{0,127})?(@sha256:[a-f0-9]{64})?$`)
+
+// templateFuncs 是模板中可以使用的函数
+var templateFuncs = template.FuncMap{
+	"quote": strconv.Quote,
+	"toJson": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+func parseTemplate(t Template) (*template.Template, error) {
+	tpl, err := template.New(t.Name).Funcs(templateFuncs).Option("missingkey=error").Parse(t.Content)
+	if err != nil {
+		return nil, fmt.Errorf("解析模板失败: %v", err)
+	}
+	return tpl, nil
+}
+
+// validateTemplate 校验模板名称、参数定义和模板语法
+func validateTemplate(t Template) error {
+	if errs := validation.IsDNS1123Label(t.Name); len(errs) > 0 {
+		return fmt.Errorf("无效的模板名称 %s: %s", t.Name, strings.Join(errs, "; "))
+	}
+	seen := make(map[string]bool)
+	for _, p := range t.Params {
+		if p.Name == "" {
+			return fmt.Errorf("参数名称不能为空")
+		}
+		if seen[p.Name] {
+			return fmt.Errorf("参数 %s 重复", p.Name)
+		}
+		seen[p.Name] = true
+		if !paramTypes[p.Type] {
+			return fmt.Errorf("参数 %s 的类型 %s 不支持", p.Name, p.Type)
+		}
+		if p.Default != nil {
+			if _, err := paramValue(p, p.Default); err != nil {
+				return fmt.Errorf("参数 %s 的默认值无效: %v", p.Name, err)
+			}
+		}
+	}
+	_, err := parseTemplate(t)
+	return err
+}
+
+// renderTemplate 校验参数并渲染模板，返回多文档 YAML
+func renderTemplate(t Template, values map[string]interface{}) (string, error) {
+	params := make(map[string]interface{}, len(t.Params))
+	defined := make(map[string]bool, len(t.Params))
+	var errs []string
+	for _, p := range t.Params {
+		defined[p.Name] = true
+		value, ok := values[p.Name]
+		if !ok || value == nil {
+			value = p.Default
+		}
+		if value == nil {
+			if p.Required {
+				errs = append(errs, fmt.Sprintf("%s: 必填参数", p.Name))
+			}
+			params[p.Name] = zeroValue(p.Type)
+			continue
+		}
+		v, err := paramValue(p, value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", p.Name, err))
+			continue
+		}
+		params[p.Name] = v
+	}
+	for name := range values {
+		if !defined[name] {
+			errs = append(errs, fmt.Sprintf("%s: 模板没有定义这个参数", name))
+		}
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return "", fmt.Errorf("参数校验失败: %s", strings.Join(errs, "; "))
+	}
+
+	tpl, err := parseTemplate(t)
+	if err != nil {
+		return "", err
+	}
+	var buf bytes.Buffer
+	if err := tpl.Execute(&buf, params); err != nil {
+		return "", fmt.Errorf("渲染模板失败: %v", err)
+	}
+	return buf.String(), nil
+}
+
+func zeroValue(paramType string) interface{} {
+	switch paramType {
+	case ParamInt:
+		return 0
+	case ParamBool:
+		return false
+	case ParamPorts:
+		return []int{}
+	case ParamEnv:
+		return map[string]string{}
+	}
+	return ""
+}
+
+// paramValue 把 JSON 中的值转换为参数类型对应的 Go 类型并校验
+func paramValue(p Param, value interface{}) (interface{}, error) {
+	switch p.Type {
+	case ParamString, ParamName, ParamImage:
+		s, ok := value.(string)
+		if !ok {
+			return nil, fmt.Errorf("应为字符串")
+		}
+		if p.Type == ParamName {
+			if errs := validation.IsDNS1123Label(s); len(errs) > 0 {
+				return nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+			}
+		}
+		if p.Type == ParamImage && !imageReference.MatchString(s) {
+			return nil, fmt.Errorf("无效的镜像地址 %s", s)
+		}
+		if err := checkInline(s); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case ParamInt:
+		n, err := intValue(value)
+		if err != nil {
+			return nil, err
+		}
+		if p.Min != nil && n < *p.Min {
+			return nil, fmt.Errorf("不能小于 %d", *p.Min)
+		}
+		if p.Max != nil && n > *p.Max {
+			return nil, fmt.Errorf("不能大于 %d", *p.Max)
+		}
+		return n, nil
+	case ParamBool:
+		b, ok := value.(bool)
+		if !ok {
+			return nil, fmt.Errorf("应为 true 或 false")
+		}
+		return b, nil
+	case ParamPorts:
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("应为端口列表")
+		}
+		ports := make([]int, 0, len(list))
+		for _, item := range list {
+			port, err := intValue(item)
+			if err != nil {
+				return nil, err
+			}
+			if errs := validation.IsValidPortNum(port); len(errs) > 0 {
+				return nil, fmt.Errorf("%d: %s", port, strings.Join(errs, "; "))
+			}
+			ports = append(ports, port)
+		}
+		return ports, nil
+	case ParamEnv:
+		m, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("应为环境变量对象")
+		}
+		env := make(map[string]string, len(m))
+		for k, v := range m {
+			if errs := validation.IsEnvVarName(k); len(errs) > 0 {
+				return nil, fmt.Errorf("无效的环境变量名 %s: %s", k, strings.Join(errs, "; "))
+			}
+			env[k] = fmt.Sprint(v)
+			if err := checkInline(env[k]); err != nil {
+				return nil, fmt.Errorf("环境变量 %s: %v", k, err)
+			}
+		}
+		return env, nil
+	}
+	return nil, fmt.Errorf("不支持的参数类型 %s", p.Type)
+}
+
+// checkInline 拒绝换行和控制字符。模板按原样输出参数值，
+// 值中带换行时可以插入新的字段或 --- 分隔的文档，apply 时会创建模板之外的对象
+func checkInline(s string) error {
+	for _, r := range s {
+		if unicode.IsControl(r) && r != '\t' {
+			return fmt.Errorf("不能包含换行或控制字符")
+		}
+	}
+	return nil
+}
+
+func intValue(value interface{}) (int, error) {
+	switch v := value.(type) {
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("应为整数")
+		}
+		return int(v), nil
+	case int:
+		return v, nil
+	case string:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return 0, fmt.Errorf("应为整数")
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("应为整数")
+}
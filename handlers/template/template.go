@@ -0,0 +1,289 @@
+package template
+
+import (
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/k8s"
+	"k8s-manage-api/store"
+	"net/http"
+	"time"
+)
+
+// templateBucket 是本地存储中保存模板的 bucket
+const templateBucket = "templates"
+
+// Template 是可复用的多文档 YAML 模板，内容使用 Go text/template 语法，例如 {{ .name }}
+type Template struct {
+	Name        string  `json:"name"`
+	Description string  `json:"description"`
+	Params      []Param `json:"params"`
+	Content     string  `json:"content"`
+	UpdateTime  string  `json:"updateTime"`
+}
+
+type Param struct {
+	Name        string      `json:"name"`
+	Type        string      `json:"type"`
+	Description string      `json:"description"`
+	Required    bool        `json:"required"`
+	Default     interface{} `json:"default,omitempty"`
+	// 只对 int 类型生效
+	Min *int `json:"min,omitempty"`
+	Max *int `json:"max,omitempty"`
+}
+
+func getTemplate(name string) (Template, error) {
+	var t Template
+	found, err := store.Get(templateBucket, name, &t)
+	if err != nil {
+		return t, fmt.Errorf("读取模板失败: %v", err)
+	}
+	if !found {
+		return t, fmt.Errorf("模板 %s 不存在", name)
+	}
+	return t, nil
+}
+
+type ListTemplateResponse struct {
+	handlers.ErrorResponse
+	Templates []Template `json:"templates"`
+}
+
+func ListTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp ListTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	err := store.List(templateBucket, func(key string, data []byte) error {
+		var t Template
+		if err := json.Unmarshal(data, &t); err != nil {
+			return fmt.Errorf("解析模板 %s 失败: %v", key, err)
+		}
+		resp.Templates = append(resp.Templates, t)
+		return nil
+	})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取模板列表失败: %v", err)
+		return
+	}
+}
+
+type TemplateNameRequest struct {
+	Name string `json:"name"`
+}
+
+type GetTemplateResponse struct {
+	handlers.ErrorResponse
+	Template Template `json:"template"`
+}
+
+func GetTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp GetTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req TemplateNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	t, err := getTemplate(req.Name)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.Template = t
+}
+
+type SaveTemplateResponse struct {
+	handlers.ErrorResponse
+}
+
+// SaveTemplate 创建或覆盖模板，保存前校验参数定义和模板语法
+func SaveTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp SaveTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var t Template
+	if err := json.NewDecoder(r.Body).Decode(&t); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := validateTemplate(t); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	t.UpdateTime = time.Now().Format("2006-01-02 15:04:05")
+	if err := store.Put(templateBucket, t.Name, t); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("保存模板失败: %v", err)
+		return
+	}
+}
+
+type DeleteTemplateResponse struct {
+	handlers.ErrorResponse
+}
+
+func DeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp DeleteTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req TemplateNameRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if err := store.Delete(templateBucket, req.Name); err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("删除模板失败: %v", err)
+		return
+	}
+}
+
+type RenderTemplateRequest struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+}
+
+type RenderTemplateResponse struct {
+	handlers.ErrorResponse
+	Yaml string `json:"yaml"`
+}
+
+// RenderTemplate 校验参数并渲染模板，同时检查渲染结果的每个文档都能被解析
+func RenderTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp RenderTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req RenderTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	yamlData, err := render(req.Name, req.Params)
+	resp.Yaml = yamlData
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+}
+
+func render(name string, params map[string]interface{}) (string, error) {
+	t, err := getTemplate(name)
+	if err != nil {
+		return "", err
+	}
+	yamlData, err := renderTemplate(t, params)
+	if err != nil {
+		return "", err
+	}
+	docs, err := k8s.YamlOperationDryRunClient.Documents(yamlData)
+	if err != nil {
+		return yamlData, err
+	}
+	if len(docs) == 0 {
+		return yamlData, fmt.Errorf("渲染结果为空")
+	}
+	for _, doc := range docs {
+		if doc.Err != nil {
+			return yamlData, fmt.Errorf("第 %d 个文档: %v", doc.Index+1, doc.Err)
+		}
+	}
+	return yamlData, nil
+}
+
+type ApplyTemplateRequest struct {
+	Name   string                 `json:"name"`
+	Params map[string]interface{} `json:"params"`
+	// 只做服务端 dry-run
+	DryRun bool `json:"dryRun"`
+	// 做 schema 校验和服务端 dry-run，并返回每个对象的 diff
+	Preview      bool   `json:"preview"`
+	FieldManager string `json:"fieldManager"`
+	Force        bool   `json:"force"`
+}
+
+type ApplyTemplateResponse struct {
+	handlers.ErrorResponse
+	Yaml    string            `json:"yaml"`
+	Results []k8s.ApplyResult `json:"results,omitempty"`
+}
+
+// ApplyTemplate 渲染模板后交给 YamlOperation 服务端 apply
+func ApplyTemplate(w http.ResponseWriter, r *http.Request) {
+	var resp ApplyTemplateResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ApplyTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	yamlData, err := render(req.Name, req.Params)
+	resp.Yaml = yamlData
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	opt := k8s.ApplyOptions{
+		FieldManager: handlers.FieldManager(r, req.FieldManager),
+		Force:        req.Force,
+	}
+	switch {
+	case req.Preview:
+		resp.Results, err = k8s.YamlOperationDryRunClient.Preview(yamlData, opt)
+	case req.DryRun:
+		resp.Results, err = k8s.YamlOperationDryRunClient.ApplyAll(yamlData, opt)
+	default:
+		resp.Results, err = k8s.YamlOperationClient.ApplyAll(yamlData, opt)
+	}
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("应用模板失败: %v", err)
+		return
+	}
+	failed := 0
+	for _, result := range resp.Results {
+		if result.Failed() {
+			failed++
+		}
+	}
+	if failed > 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("%d 个对象应用失败", failed)
+	}
+}
@@ -7,27 +7,40 @@ import (
 	"k8s-manage-api/handlers"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"sort"
+	"strings"
+	"time"
 
+	"github.com/robfig/cron/v3"
+	batchv1 "k8s.io/api/batch/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type ListCronJobRequest struct {
+	handlers.ListQuery
 	CronJobName string `json:"CronJobName"`
 	NameSpace   string `json:"namespace"`
 }
 
 type ListCronJobResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	CronJobs []CronJob `json:"cronJobs"`
 }
 
 type CronJob struct {
-	Name       string            `json:"name"`
-	Namespace  string            `json:"namespace"`
-	Labels     map[string]string `json:"labels"`
-	Images     []string          `json:"images"`
-	Pods       string            `json:"pods"`
-	CreateTime string            `json:"createTime"`
+	Name             string            `json:"name"`
+	Namespace        string            `json:"namespace"`
+	Labels           map[string]string `json:"labels"`
+	Images           []string          `json:"images"`
+	Pods             string            `json:"pods"`
+	CreateTime       string            `json:"createTime"`
+	Schedule         string            `json:"schedule"`
+	TimeZone         string            `json:"timeZone"`
+	Suspend          bool              `json:"suspend"`
+	Active           int               `json:"active"`
+	LastScheduleTime string            `json:"lastScheduleTime"`
 }
 
 func ListCronJob(w http.ResponseWriter, r *http.Request) {
@@ -46,7 +59,7 @@ func ListCronJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.CronJobName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.CronJobName)
 	}
@@ -56,7 +69,21 @@ func ListCronJob(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *batchv1.CronJob) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       cronJobStatus(svc),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.CronJobs = append(resp.CronJobs, CronJob{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
@@ -69,7 +96,328 @@ func ListCronJob(w http.ResponseWriter, r *http.Request) {
 				return images
 			}(),
 			CreateTime: svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+			Schedule:   svc.Spec.Schedule,
+			TimeZone: func() string {
+				if svc.Spec.TimeZone != nil {
+					return *svc.Spec.TimeZone
+				}
+				return ""
+			}(),
+			Suspend: svc.Spec.Suspend != nil && *svc.Spec.Suspend,
+			Active:  len(svc.Status.Active),
+			LastScheduleTime: func() string {
+				if svc.Status.LastScheduleTime != nil {
+					return svc.Status.LastScheduleTime.Format("2006-01-02 15:04:05")
+				}
+				return ""
+			}(),
 		})
 	}
 	return
 }
+
+// cronJobStatus 用于列表按状态过滤
+func cronJobStatus(cronJob *batchv1.CronJob) string {
+	if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+		return "Suspended"
+	}
+	if len(cronJob.Status.Active) > 0 {
+		return "Active"
+	}
+	return "Scheduled"
+}
+
+type TriggerCronJobRequest struct {
+	CronJobName string `json:"cronJobName"`
+	NameSpace   string `json:"namespace"`
+}
+
+type TriggerCronJobResponse struct {
+	handlers.ErrorResponse
+	JobName string `json:"jobName"`
+}
+
+// TriggerCronJob 立即根据 CronJob 的模板创建一个 Job，效果等同于 kubectl create job --from=cronjob/xxx
+func TriggerCronJob(w http.ResponseWriter, r *http.Request) {
+	var resp TriggerCronJobResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req TriggerCronJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.CronJobName == "" || req.NameSpace == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "cronJobName 和 namespace 不能为空"
+		return
+	}
+
+	clientset := k8s.GetClient()
+	cronJob, err := clientset.BatchV1().CronJobs(req.NameSpace).Get(context.TODO(), req.CronJobName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get cronjob err: %s", err.Error())
+		return
+	}
+
+	job := jobFromCronJob(cronJob)
+	created, err := clientset.BatchV1().Jobs(req.NameSpace).Create(context.TODO(), job, metav1.CreateOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("创建 Job 失败: %v", err)
+		return
+	}
+	resp.JobName = created.Name
+}
+
+// jobFromCronJob 与 kubectl 的实现保持一致：复制 jobTemplate，并打上手动触发的注解和 ownerReference
+func jobFromCronJob(cronJob *batchv1.CronJob) *batchv1.Job {
+	annotations := map[string]string{
+		"cronjob.kubernetes.io/instantiate": "manual",
+	}
+	for k, v := range cronJob.Spec.JobTemplate.Annotations {
+		annotations[k] = v
+	}
+
+	// Job 名称会写入 Pod 的标签，长度不能超过 63，预留随机后缀的长度
+	name := cronJob.Name
+	if len(name) > 42 {
+		name = name[:42]
+	}
+	return &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"},
+		ObjectMeta: metav1.ObjectMeta{
+			// 同一秒内多次触发也不会重名
+			GenerateName: name + "-manual-",
+			Namespace:    cronJob.Namespace,
+			Annotations:  annotations,
+			Labels:       cronJob.Spec.JobTemplate.Labels,
+			OwnerReferences: []metav1.OwnerReference{
+				*metav1.NewControllerRef(cronJob, batchv1.SchemeGroupVersion.WithKind("CronJob")),
+			},
+		},
+		Spec: cronJob.Spec.JobTemplate.Spec,
+	}
+}
+
+type SuspendCronJobRequest struct {
+	CronJobName string `json:"cronJobName"`
+	NameSpace   string `json:"namespace"`
+	Suspend     bool   `json:"suspend"`
+}
+
+type SuspendCronJobResponse struct {
+	handlers.ErrorResponse
+}
+
+// SuspendCronJob 暂停或恢复 CronJob 的调度
+func SuspendCronJob(w http.ResponseWriter, r *http.Request) {
+	var resp SuspendCronJobResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req SuspendCronJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.CronJobName == "" || req.NameSpace == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "cronJobName 和 namespace 不能为空"
+		return
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"suspend":%t}}`, req.Suspend)
+	clientset := k8s.GetClient()
+	_, err := clientset.BatchV1().CronJobs(req.NameSpace).Patch(context.TODO(), req.CronJobName, types.MergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("更新 CronJob 失败: %v", err)
+		return
+	}
+}
+
+type ListCronJobJobsRequest struct {
+	CronJobName string `json:"cronJobName"`
+	NameSpace   string `json:"namespace"`
+}
+
+type ListCronJobJobsResponse struct {
+	handlers.ErrorResponse
+	Jobs []CronJobRun `json:"jobs"`
+}
+
+type CronJobRun struct {
+	Name           string `json:"name"`
+	Status         string `json:"status"`
+	Manual         bool   `json:"manual"`
+	Succeeded      int32  `json:"succeeded"`
+	Failed         int32  `json:"failed"`
+	StartTime      string `json:"startTime"`
+	CompletionTime string `json:"completionTime"`
+}
+
+// ListCronJobJobs 列出 CronJob 创建的 Job 以及执行结果，按开始时间倒序
+func ListCronJobJobs(w http.ResponseWriter, r *http.Request) {
+	var resp ListCronJobJobsResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ListCronJobJobsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	cronJob, err := clientset.BatchV1().CronJobs(req.NameSpace).Get(context.TODO(), req.CronJobName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get cronjob err: %s", err.Error())
+		return
+	}
+	jobs, err := clientset.BatchV1().Jobs(req.NameSpace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取job列表失败: %v", err)
+		return
+	}
+
+	var owned []batchv1.Job
+	for _, job := range jobs.Items {
+		ref := metav1.GetControllerOf(&job)
+		if ref != nil && ref.UID == cronJob.UID {
+			owned = append(owned, job)
+		}
+	}
+	sort.Slice(owned, func(i, j int) bool {
+		return owned[j].CreationTimestamp.Before(&owned[i].CreationTimestamp)
+	})
+	for _, job := range owned {
+		run := CronJobRun{
+			Name:      job.Name,
+			Status:    JobStatus(&job),
+			Manual:    job.Annotations["cronjob.kubernetes.io/instantiate"] == "manual",
+			Succeeded: job.Status.Succeeded,
+			Failed:    job.Status.Failed,
+		}
+		if job.Status.StartTime != nil {
+			run.StartTime = job.Status.StartTime.Format("2006-01-02 15:04:05")
+		}
+		if job.Status.CompletionTime != nil {
+			run.CompletionTime = job.Status.CompletionTime.Format("2006-01-02 15:04:05")
+		}
+		resp.Jobs = append(resp.Jobs, run)
+	}
+}
+
+type PreviewCronJobScheduleRequest struct {
+	CronJobName string `json:"cronJobName"`
+	NameSpace   string `json:"namespace"`
+	// 不指定 CronJob 时直接使用 Schedule 和 TimeZone 计算，用于创建前预览
+	Schedule string `json:"schedule"`
+	TimeZone string `json:"timeZone"`
+	Count    int    `json:"count"`
+}
+
+type PreviewCronJobScheduleResponse struct {
+	handlers.ErrorResponse
+	Schedule string   `json:"schedule"`
+	TimeZone string   `json:"timeZone"`
+	NextRuns []string `json:"nextRuns"`
+}
+
+// PreviewCronJobSchedule 根据 cron 表达式和时区计算接下来 N 次的调度时间
+func PreviewCronJobSchedule(w http.ResponseWriter, r *http.Request) {
+	var resp PreviewCronJobScheduleResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req PreviewCronJobScheduleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	if req.CronJobName != "" {
+		clientset := k8s.GetClient()
+		cronJob, err := clientset.BatchV1().CronJobs(req.NameSpace).Get(context.TODO(), req.CronJobName, metav1.GetOptions{})
+		if err != nil {
+			resp.ErrorCode = "400"
+			resp.ErrorMessage = fmt.Sprintf("get cronjob err: %s", err.Error())
+			return
+		}
+		req.Schedule = cronJob.Spec.Schedule
+		req.TimeZone = ""
+		if cronJob.Spec.TimeZone != nil {
+			req.TimeZone = *cronJob.Spec.TimeZone
+		}
+	}
+	if req.Count <= 0 {
+		req.Count = 5
+	}
+	if req.Count > 100 {
+		req.Count = 100
+	}
+
+	nextRuns, err := nextSchedules(req.Schedule, req.TimeZone, time.Now(), req.Count)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.Schedule = req.Schedule
+	resp.TimeZone = req.TimeZone
+	for _, t := range nextRuns {
+		resp.NextRuns = append(resp.NextRuns, t.Format("2006-01-02 15:04:05 MST"))
+	}
+}
+
+// nextSchedules 使用与 CronJob 控制器相同的 cron 解析规则计算调度时间。
+// 未指定时区时控制器使用 kube-controller-manager 所在的本地时区，这里同样使用本地时区。
+func nextSchedules(schedule, timeZone string, from time.Time, count int) ([]time.Time, error) {
+	if strings.Contains(schedule, "TZ=") {
+		return nil, fmt.Errorf("schedule 中不支持 TZ/CRON_TZ，请使用 timeZone 字段")
+	}
+	loc := time.Local
+	if timeZone != "" {
+		l, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("无效的时区 %s: %v", timeZone, err)
+		}
+		loc = l
+	}
+	sched, err := cron.ParseStandard(schedule)
+	if err != nil {
+		return nil, fmt.Errorf("无效的 cron 表达式 %s: %v", schedule, err)
+	}
+
+	var result []time.Time
+	t := from.In(loc)
+	for i := 0; i < count; i++ {
+		t = sched.Next(t)
+		if t.IsZero() {
+			break
+		}
+		result = append(result, t)
+	}
+	return result, nil
+}
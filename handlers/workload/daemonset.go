@@ -7,17 +7,28 @@ import (
 	"k8s-manage-api/handlers"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
+	nodepool "k8s-manage-api/handlers/node_pool"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/selection"
 )
 
 type ListDaemonsetRequest struct {
+	handlers.ListQuery
 	DaemonsetName string `json:"daemonsetName"`
 	NameSpace     string `json:"namespace"`
 }
 
 type ListDaemonsetResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Daemonsets []Daemonset `json:"daemonsets"`
 }
 
@@ -46,7 +57,7 @@ func ListDaemonset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.DaemonsetName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.DaemonsetName)
 	}
@@ -56,7 +67,21 @@ func ListDaemonset(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *appsv1.DaemonSet) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       readyStatus(svc.Status.NumberReady, svc.Status.DesiredNumberScheduled),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Daemonsets = append(resp.Daemonsets, Daemonset{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
@@ -74,3 +99,374 @@ func ListDaemonset(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+type DaemonSetCoverageRequest struct {
+	DaemonsetName string `json:"daemonsetName"`
+	NameSpace     string `json:"namespace"`
+}
+
+type DaemonSetCoverageResponse struct {
+	handlers.ErrorResponse
+	Coverages []DaemonSetCoverage `json:"coverages"`
+}
+
+type DaemonSetCoverage struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	// 应该运行 Pod 的节点数
+	Desired int `json:"desired"`
+	// 应该运行 Pod 且已经有 Pod 的节点数，与 DaemonSet 的 currentNumberScheduled 一致，
+	// 不满足调度条件但仍有 Pod 的节点计入 Misscheduled
+	Scheduled    int                 `json:"scheduled"`
+	Missing      int                 `json:"missing"`
+	Misscheduled int                 `json:"misscheduled"`
+	Nodes        []DaemonSetNode     `json:"nodes"`
+	NodePools    []DaemonSetNodePool `json:"nodePools"`
+}
+
+type DaemonSetNode struct {
+	Name     string `json:"name"`
+	NodePool string `json:"nodePool"`
+	// Running、Missing、Misscheduled 或 Skipped
+	State     string `json:"state"`
+	ShouldRun bool   `json:"shouldRun"`
+	PodName   string `json:"podName"`
+	PodStatus string `json:"podStatus"`
+	Reason    string `json:"reason"`
+}
+
+type DaemonSetNodePool struct {
+	Name         string `json:"name"`
+	Nodes        int    `json:"nodes"`
+	Running      int    `json:"running"`
+	Missing      int    `json:"missing"`
+	Misscheduled int    `json:"misscheduled"`
+	Skipped      int    `json:"skipped"`
+}
+
+const (
+	DaemonSetNodeRunning      = "Running"
+	DaemonSetNodeMissing      = "Missing"
+	DaemonSetNodeMisscheduled = "Misscheduled"
+	DaemonSetNodeSkipped      = "Skipped"
+)
+
+// GetDaemonSetCoverage 计算 DaemonSet 应该运行在哪些节点上，并与实际运行的 Pod 对比，
+// 找出缺失和错误调度的节点以及原因
+func GetDaemonSetCoverage(w http.ResponseWriter, r *http.Request) {
+	clientset := k8s.GetClient()
+	var resp DaemonSetCoverageResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DaemonSetCoverageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	listOptions := metav1.ListOptions{}
+	if req.DaemonsetName != "" {
+		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.DaemonsetName)
+	}
+	daemonsets, err := clientset.AppsV1().DaemonSets(req.NameSpace).List(context.Background(), listOptions)
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取daemonset列表失败: %v", err)
+		return
+	}
+	nodes, err := clientset.CoreV1().Nodes().List(context.Background(), metav1.ListOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取节点列表失败: %v", err)
+		return
+	}
+
+	for _, ds := range daemonsets.Items {
+		selector, err := metav1.LabelSelectorAsSelector(ds.Spec.Selector)
+		if err != nil {
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = fmt.Sprintf("daemonset %s selector 无效: %v", ds.Name, err)
+			return
+		}
+		pods, err := clientset.CoreV1().Pods(ds.Namespace).List(context.Background(), metav1.ListOptions{
+			LabelSelector: selector.String(),
+		})
+		if err != nil {
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = fmt.Sprintf("获取pod列表失败: %v", err)
+			return
+		}
+		resp.Coverages = append(resp.Coverages, daemonSetCoverage(&ds, nodes.Items, pods.Items))
+	}
+}
+
+func daemonSetCoverage(ds *appsv1.DaemonSet, nodes []corev1.Node, pods []corev1.Pod) DaemonSetCoverage {
+	coverage := DaemonSetCoverage{
+		Name:      ds.Name,
+		Namespace: ds.Namespace,
+	}
+
+	// 只统计由该 DaemonSet 创建的 Pod
+	nodePods := make(map[string]*corev1.Pod)
+	for i := range pods {
+		pod := &pods[i]
+		ref := metav1.GetControllerOf(pod)
+		if ref == nil || ref.UID != ds.UID {
+			continue
+		}
+		nodeName := daemonSetPodNode(pod)
+		if nodeName == "" {
+			continue
+		}
+		nodePods[nodeName] = pod
+	}
+
+	tolerations := daemonSetTolerations(&ds.Spec.Template.Spec)
+	pools := make(map[string]*DaemonSetNodePool)
+	for _, node := range nodes {
+		shouldRun, shouldContinueRunning, reason := nodeShouldRunDaemonPod(&ds.Spec.Template.Spec, tolerations, &node)
+		item := DaemonSetNode{
+			Name:      node.Name,
+			NodePool:  nodepool.NodePoolName(node),
+			ShouldRun: shouldRun,
+			Reason:    reason,
+		}
+		pod, hasPod := nodePods[node.Name]
+		if hasPod {
+			item.PodName = pod.Name
+			item.PodStatus = Phase(pod)
+		}
+
+		switch {
+		case shouldRun && hasPod:
+			item.State = DaemonSetNodeRunning
+		case shouldRun && !hasPod:
+			item.State = DaemonSetNodeMissing
+			item.Reason = "节点满足调度条件但没有 Pod，可能是 Pod 正在创建、被驱逐或 DaemonSet 处于滚动更新中"
+		case !shouldRun && hasPod:
+			// 与 DaemonSet 的 numberMisscheduled 一致，不满足调度条件的节点上的 Pod 都算 misscheduled
+			item.State = DaemonSetNodeMisscheduled
+			if shouldContinueRunning {
+				// 控制器不会在这个节点上新建 Pod，但也不会删除已有的 Pod
+				item.Reason = reason + "，已有的 Pod 继续运行"
+			}
+		default:
+			item.State = DaemonSetNodeSkipped
+		}
+
+		if _, ok := pools[item.NodePool]; !ok {
+			pools[item.NodePool] = &DaemonSetNodePool{Name: item.NodePool}
+		}
+		pool := pools[item.NodePool]
+		pool.Nodes++
+		switch item.State {
+		case DaemonSetNodeRunning:
+			pool.Running++
+			coverage.Desired++
+			coverage.Scheduled++
+		case DaemonSetNodeMissing:
+			pool.Missing++
+			coverage.Desired++
+			coverage.Missing++
+		case DaemonSetNodeMisscheduled:
+			pool.Misscheduled++
+			coverage.Misscheduled++
+		case DaemonSetNodeSkipped:
+			pool.Skipped++
+		}
+		coverage.Nodes = append(coverage.Nodes, item)
+	}
+
+	for _, pool := range pools {
+		coverage.NodePools = append(coverage.NodePools, *pool)
+	}
+	sort.Slice(coverage.NodePools, func(i, j int) bool {
+		return coverage.NodePools[i].Name < coverage.NodePools[j].Name
+	})
+	return coverage
+}
+
+// daemonSetPodNode 返回 Pod 所在的节点，还未绑定节点的 DaemonSet Pod 通过 nodeAffinity 中的 metadata.name 确定目标节点
+func daemonSetPodNode(pod *corev1.Pod) string {
+	if pod.Spec.NodeName != "" {
+		return pod.Spec.NodeName
+	}
+	affinity := pod.Spec.Affinity
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return ""
+	}
+	for _, term := range affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms {
+		for _, field := range term.MatchFields {
+			if field.Key == "metadata.name" && field.Operator == corev1.NodeSelectorOpIn && len(field.Values) == 1 {
+				return field.Values[0]
+			}
+		}
+	}
+	return ""
+}
+
+// daemonSetTolerations 返回 DaemonSet 控制器会自动为 Pod 添加的容忍，再加上模板中声明的容忍
+func daemonSetTolerations(spec *corev1.PodSpec) []corev1.Toleration {
+	tolerations := append([]corev1.Toleration{}, spec.Tolerations...)
+	tolerations = append(tolerations,
+		corev1.Toleration{Key: corev1.TaintNodeNotReady, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+		corev1.Toleration{Key: corev1.TaintNodeUnreachable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoExecute},
+		corev1.Toleration{Key: corev1.TaintNodeDiskPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		corev1.Toleration{Key: corev1.TaintNodeMemoryPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		corev1.Toleration{Key: corev1.TaintNodePIDPressure, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+		corev1.Toleration{Key: corev1.TaintNodeUnschedulable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule},
+	)
+	if spec.HostNetwork {
+		tolerations = append(tolerations, corev1.Toleration{
+			Key: corev1.TaintNodeNetworkUnavailable, Operator: corev1.TolerationOpExists, Effect: corev1.TaintEffectNoSchedule,
+		})
+	}
+	return tolerations
+}
+
+// nodeShouldRunDaemonPod 依次检查 nodeName、nodeSelector、required nodeAffinity 和污点容忍，
+// 返回节点是否应该新建 Pod、已有的 Pod 是否应该继续运行以及不满足的原因。
+// 与 DaemonSet 控制器一致，未容忍的 NoSchedule 污点只阻止新建 Pod，NoExecute 污点才会驱逐已有的 Pod
+func nodeShouldRunDaemonPod(spec *corev1.PodSpec, tolerations []corev1.Toleration, node *corev1.Node) (shouldRun, shouldContinueRunning bool, reason string) {
+	if spec.NodeName != "" && spec.NodeName != node.Name {
+		return false, false, fmt.Sprintf("spec.nodeName 指定了节点 %s", spec.NodeName)
+	}
+	if len(spec.NodeSelector) > 0 {
+		selector := labels.SelectorFromSet(spec.NodeSelector)
+		if !selector.Matches(labels.Set(node.Labels)) {
+			return false, false, fmt.Sprintf("节点标签不满足 nodeSelector %s", selector.String())
+		}
+	}
+	if ok, reason := matchRequiredNodeAffinity(spec.Affinity, node); !ok {
+		return false, false, reason
+	}
+	shouldRun, shouldContinueRunning = true, true
+	for _, taint := range node.Spec.Taints {
+		if taint.Effect == corev1.TaintEffectPreferNoSchedule || tolerationsTolerateTaint(tolerations, &taint) {
+			continue
+		}
+		if taint.Effect == corev1.TaintEffectNoExecute {
+			return false, false, fmt.Sprintf("节点污点 %s 未被容忍", taintString(taint))
+		}
+		if shouldRun {
+			shouldRun = false
+			reason = fmt.Sprintf("节点污点 %s 未被容忍", taintString(taint))
+		}
+	}
+	return shouldRun, shouldContinueRunning, reason
+}
+
+// matchRequiredNodeAffinity 检查 requiredDuringSchedulingIgnoredDuringExecution，多个 term 之间是或的关系
+func matchRequiredNodeAffinity(affinity *corev1.Affinity, node *corev1.Node) (bool, string) {
+	if affinity == nil || affinity.NodeAffinity == nil || affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution == nil {
+		return true, ""
+	}
+	terms := affinity.NodeAffinity.RequiredDuringSchedulingIgnoredDuringExecution.NodeSelectorTerms
+	var reasons []string
+	for _, term := range terms {
+		ok, reason := matchNodeSelectorTerm(term, node)
+		if ok {
+			return true, ""
+		}
+		reasons = append(reasons, reason)
+	}
+	return false, "节点不满足 required nodeAffinity: " + strings.Join(reasons, "; ")
+}
+
+func matchNodeSelectorTerm(term corev1.NodeSelectorTerm, node *corev1.Node) (bool, string) {
+	// 空的 term 不匹配任何节点
+	if len(term.MatchExpressions) == 0 && len(term.MatchFields) == 0 {
+		return false, "空的 nodeSelectorTerm"
+	}
+	for _, expr := range term.MatchExpressions {
+		ok, err := matchNodeSelectorRequirement(expr, labels.Set(node.Labels))
+		if err != nil {
+			return false, err.Error()
+		}
+		if !ok {
+			return false, fmt.Sprintf("%s %s %v", expr.Key, expr.Operator, expr.Values)
+		}
+	}
+	for _, field := range term.MatchFields {
+		ok, err := matchNodeNameField(field, node.Name)
+		if err != nil {
+			return false, err.Error()
+		}
+		if !ok {
+			return false, fmt.Sprintf("%s %s %v", field.Key, field.Operator, field.Values)
+		}
+	}
+	return true, ""
+}
+
+var nodeSelectorOperators = map[corev1.NodeSelectorOperator]selection.Operator{
+	corev1.NodeSelectorOpIn:           selection.In,
+	corev1.NodeSelectorOpNotIn:        selection.NotIn,
+	corev1.NodeSelectorOpExists:       selection.Exists,
+	corev1.NodeSelectorOpDoesNotExist: selection.DoesNotExist,
+	corev1.NodeSelectorOpGt:           selection.GreaterThan,
+	corev1.NodeSelectorOpLt:           selection.LessThan,
+}
+
+func matchNodeSelectorRequirement(expr corev1.NodeSelectorRequirement, set labels.Set) (bool, error) {
+	op, ok := nodeSelectorOperators[expr.Operator]
+	if !ok {
+		return false, fmt.Errorf("不支持的操作符 %s", expr.Operator)
+	}
+	values := expr.Values
+	if op == selection.GreaterThan || op == selection.LessThan {
+		if len(values) != 1 {
+			return false, fmt.Errorf("%s 操作符只能有一个值", expr.Operator)
+		}
+		if _, err := strconv.ParseInt(values[0], 10, 64); err != nil {
+			return false, fmt.Errorf("%s 操作符的值必须是整数: %s", expr.Operator, values[0])
+		}
+	}
+	requirement, err := labels.NewRequirement(expr.Key, op, values)
+	if err != nil {
+		return false, err
+	}
+	return requirement.Matches(set), nil
+}
+
+// matchNodeNameField 匹配 matchFields，只支持 metadata.name 的 In 和 NotIn。
+// 节点名称可能超过标签值 63 个字符的限制，不能用 labels.Requirement 匹配，直接比较名称
+func matchNodeNameField(field corev1.NodeSelectorRequirement, nodeName string) (bool, error) {
+	if field.Key != "metadata.name" {
+		return false, fmt.Errorf("matchFields 不支持的字段 %s", field.Key)
+	}
+	found := false
+	for _, v := range field.Values {
+		if v == nodeName {
+			found = true
+		}
+	}
+	switch field.Operator {
+	case corev1.NodeSelectorOpIn:
+		return found, nil
+	case corev1.NodeSelectorOpNotIn:
+		return !found, nil
+	}
+	return false, fmt.Errorf("matchFields 不支持的操作符 %s", field.Operator)
+}
+
+func tolerationsTolerateTaint(tolerations []corev1.Toleration, taint *corev1.Taint) bool {
+	for i := range tolerations {
+		if tolerations[i].ToleratesTaint(taint) {
+			return true
+		}
+	}
+	return false
+}
+
+func taintString(taint corev1.Taint) string {
+	if taint.Value == "" {
+		return fmt.Sprintf("%s:%s", taint.Key, taint.Effect)
+	}
+	return fmt.Sprintf("%s=%s:%s", taint.Key, taint.Value, taint.Effect)
+}
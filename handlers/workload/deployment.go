@@ -8,16 +8,18 @@ import (
 	"k8s-manage-api/k8s"
 	"net/http"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "k8s.io/api/apps/v1"
 )
 
 type ListDeploymentRequest struct {
+	handlers.ListQuery
 	DeploymentName string `json:"deploymentName"`
 	NameSpace      string `json:"namespace"`
 }
 
 type ListDeploymentResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Deployments []Deployment `json:"deployments"`
 }
 
@@ -46,7 +48,7 @@ func ListDeployment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.DeploymentName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.DeploymentName)
 	}
@@ -56,7 +58,21 @@ func ListDeployment(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *appsv1.Deployment) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       readyStatus(svc.Status.ReadyReplicas, svc.Status.Replicas),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Deployments = append(resp.Deployments, Deployment{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
@@ -74,3 +90,11 @@ func ListDeployment(w http.ResponseWriter, r *http.Request) {
 	}
 	return
 }
+
+// readyStatus 用于列表按状态过滤，所有副本就绪时为 Ready
+func readyStatus(ready, desired int32) string {
+	if ready >= desired {
+		return "Ready"
+	}
+	return "NotReady"
+}
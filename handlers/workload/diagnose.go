@@ -0,0 +1,428 @@
+package workload
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"k8s-manage-api/handlers"
+	"k8s-manage-api/handlers/event"
+	"k8s-manage-api/k8s"
+	"net/http"
+	"sort"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type DiagnosePodRequest struct {
+	PodName   string `json:"podName"`
+	NameSpace string `json:"namespace"`
+	// 上一次容器日志的行数，默认 50
+	TailLines int64 `json:"tailLines"`
+}
+
+type DiagnosePodResponse struct {
+	handlers.ErrorResponse
+	Diagnosis PodDiagnosis `json:"diagnosis"`
+}
+
+type PodDiagnosis struct {
+	PodName   string `json:"podName"`
+	Namespace string `json:"namespace"`
+	NodeName  string `json:"nodeName"`
+	Status    string `json:"status"`
+	// 按可能性从高到低排列的原因
+	Causes            []DiagnosisCause     `json:"causes"`
+	Containers        []ContainerDiagnosis `json:"containers"`
+	Events            []DiagnosisEvent     `json:"events"`
+	SchedulingMessage string               `json:"schedulingMessage"`
+	NodeConditions    []DiagnosisCondition `json:"nodeConditions"`
+}
+
+type DiagnosisCause struct {
+	Title    string   `json:"title"`
+	Score    int      `json:"score"`
+	Evidence []string `json:"evidence"`
+	Hints    []string `json:"hints"`
+}
+
+type ContainerDiagnosis struct {
+	Name           string `json:"name"`
+	Init           bool   `json:"init"`
+	Ready          bool   `json:"ready"`
+	State          string `json:"state"`
+	Reason         string `json:"reason"`
+	Message        string `json:"message"`
+	RestartCount   int32  `json:"restartCount"`
+	LastReason     string `json:"lastReason"`
+	LastExitCode   int32  `json:"lastExitCode"`
+	LastMessage    string `json:"lastMessage"`
+	LastFinishedAt string `json:"lastFinishedAt"`
+	MemoryLimit    string `json:"memoryLimit"`
+	PreviousLogs   string `json:"previousLogs"`
+}
+
+type DiagnosisEvent struct {
+	Type     string `json:"type"`
+	Reason   string `json:"reason"`
+	Message  string `json:"message"`
+	Count    int32  `json:"count"`
+	LastTime string `json:"lastTime"`
+}
+
+type DiagnosisCondition struct {
+	Type    string `json:"type"`
+	Status  string `json:"status"`
+	Reason  string `json:"reason"`
+	Message string `json:"message"`
+}
+
+// DiagnosePod 收集 Pod 状态、事件、上次退出信息、上一次容器日志、调度失败信息和节点状态，
+// 分析出最可能的故障原因和处理建议
+func DiagnosePod(w http.ResponseWriter, r *http.Request) {
+	var resp DiagnosePodResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DiagnosePodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.TailLines <= 0 {
+		req.TailLines = 50
+	}
+
+	ctx := r.Context()
+	clientset := k8s.GetClient()
+	pod, err := clientset.CoreV1().Pods(req.NameSpace).Get(ctx, req.PodName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get pod err: %s", err.Error())
+		return
+	}
+
+	diagnosis := PodDiagnosis{
+		PodName:   pod.Name,
+		Namespace: pod.Namespace,
+		NodeName:  pod.Spec.NodeName,
+		Status:    Phase(pod),
+	}
+
+	// 事件获取失败不影响其他信息的分析
+	events, err := clientset.CoreV1().Events(pod.Namespace).List(ctx, metav1.ListOptions{
+		FieldSelector: fmt.Sprintf("involvedObject.kind=Pod,involvedObject.name=%s,involvedObject.uid=%s", pod.Name, pod.UID),
+	})
+	if err == nil {
+		diagnosis.Events = diagnosisEvents(events.Items)
+	}
+
+	diagnosis.Containers = containerDiagnoses(pod)
+	for i := range diagnosis.Containers {
+		c := &diagnosis.Containers[i]
+		if c.RestartCount == 0 && c.LastReason == "" {
+			continue
+		}
+		c.PreviousLogs = previousLogs(ctx, pod, c.Name, req.TailLines)
+	}
+
+	for _, cond := range pod.Status.Conditions {
+		if cond.Type == v1.PodScheduled && cond.Status == v1.ConditionFalse {
+			diagnosis.SchedulingMessage = cond.Message
+		}
+	}
+
+	if pod.Spec.NodeName != "" {
+		node, err := clientset.CoreV1().Nodes().Get(ctx, pod.Spec.NodeName, metav1.GetOptions{})
+		if err == nil {
+			diagnosis.NodeConditions = unhealthyNodeConditions(node)
+		}
+	}
+
+	diagnosis.Causes = analyzePod(pod, &diagnosis)
+	resp.Diagnosis = diagnosis
+}
+
+func diagnosisEvents(events []v1.Event) []DiagnosisEvent {
+	sort.Slice(events, func(i, j int) bool {
+		return event.EventTime(&events[j]).Before(event.EventTime(&events[i]))
+	})
+	var result []DiagnosisEvent
+	for _, e := range events {
+		result = append(result, DiagnosisEvent{
+			Type:     e.Type,
+			Reason:   e.Reason,
+			Message:  e.Message,
+			Count:    e.Count,
+			LastTime: event.EventTime(&e).Format("2006-01-02 15:04:05"),
+		})
+	}
+	return result
+}
+
+func containerDiagnoses(pod *v1.Pod) []ContainerDiagnosis {
+	limits := make(map[string]string)
+	for _, c := range append(append([]v1.Container{}, pod.Spec.InitContainers...), pod.Spec.Containers...) {
+		if mem, ok := c.Resources.Limits[v1.ResourceMemory]; ok {
+			limits[c.Name] = mem.String()
+		}
+	}
+
+	var result []ContainerDiagnosis
+	add := func(statuses []v1.ContainerStatus, init bool) {
+		for _, cs := range statuses {
+			c := ContainerDiagnosis{
+				Name:         cs.Name,
+				Init:         init,
+				Ready:        cs.Ready,
+				RestartCount: cs.RestartCount,
+				MemoryLimit:  limits[cs.Name],
+			}
+			switch {
+			case cs.State.Waiting != nil:
+				c.State = "Waiting"
+				c.Reason = cs.State.Waiting.Reason
+				c.Message = cs.State.Waiting.Message
+			case cs.State.Terminated != nil:
+				c.State = "Terminated"
+				c.Reason = cs.State.Terminated.Reason
+				c.Message = cs.State.Terminated.Message
+			case cs.State.Running != nil:
+				c.State = "Running"
+			}
+			if t := cs.LastTerminationState.Terminated; t != nil {
+				c.LastReason = t.Reason
+				c.LastExitCode = t.ExitCode
+				c.LastMessage = t.Message
+				c.LastFinishedAt = t.FinishedAt.Format("2006-01-02 15:04:05")
+			} else if t := cs.State.Terminated; t != nil && t.ExitCode != 0 {
+				// 还没有重启过的容器，本次退出信息就是最近一次的失败
+				c.LastReason = t.Reason
+				c.LastExitCode = t.ExitCode
+				c.LastMessage = t.Message
+				c.LastFinishedAt = t.FinishedAt.Format("2006-01-02 15:04:05")
+			}
+			result = append(result, c)
+		}
+	}
+	add(pod.Status.InitContainerStatuses, true)
+	add(pod.Status.ContainerStatuses, false)
+	return result
+}
+
+// previousLogs 获取容器上一次运行的日志尾部，容器没有上一次运行时返回当前日志
+func previousLogs(ctx context.Context, pod *v1.Pod, container string, tailLines int64) string {
+	clientset := k8s.GetClient()
+	for _, previous := range []bool{true, false} {
+		data, err := clientset.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+			Container: container,
+			Previous:  previous,
+			TailLines: &tailLines,
+		}).DoRaw(ctx)
+		if err == nil {
+			return string(data)
+		}
+	}
+	return ""
+}
+
+func unhealthyNodeConditions(node *v1.Node) []DiagnosisCondition {
+	var result []DiagnosisCondition
+	for _, cond := range node.Status.Conditions {
+		healthy := cond.Status == v1.ConditionFalse
+		if cond.Type == v1.NodeReady {
+			healthy = cond.Status == v1.ConditionTrue
+		}
+		if healthy {
+			continue
+		}
+		result = append(result, DiagnosisCondition{
+			Type:    string(cond.Type),
+			Status:  string(cond.Status),
+			Reason:  cond.Reason,
+			Message: cond.Message,
+		})
+	}
+	if node.Spec.Unschedulable {
+		result = append(result, DiagnosisCondition{
+			Type:    "Unschedulable",
+			Status:  "True",
+			Message: "节点已被 cordon",
+		})
+	}
+	return result
+}
+
+// analyzePod 根据收集到的信息按规则给出可能的原因，分数越高可能性越大
+func analyzePod(pod *v1.Pod, d *PodDiagnosis) []DiagnosisCause {
+	var causes []DiagnosisCause
+	add := func(score int, title string, evidence []string, hints ...string) {
+		causes = append(causes, DiagnosisCause{Title: title, Score: score, Evidence: evidence, Hints: hints})
+	}
+	eventMessages := func(reasons ...string) []string {
+		var msgs []string
+		for _, e := range d.Events {
+			for _, reason := range reasons {
+				if e.Reason == reason {
+					msgs = append(msgs, fmt.Sprintf("%s: %s", e.Reason, e.Message))
+				}
+			}
+		}
+		return msgs
+	}
+
+	if pod.Status.Reason == "Evicted" {
+		add(95, "Pod 被节点驱逐", []string{pod.Status.Message},
+			"驱逐通常由节点内存、磁盘或 PID 压力引起，检查节点资源使用情况",
+			"为容器设置合理的 requests，避免 BestEffort Pod 被优先驱逐")
+	}
+
+	for _, c := range d.Containers {
+		prefix := fmt.Sprintf("容器 %s", c.Name)
+		if c.Init {
+			prefix = fmt.Sprintf("Init 容器 %s", c.Name)
+		}
+		switch c.Reason {
+		case "ImagePullBackOff", "ErrImagePull", "InvalidImageName", "ErrImageNeverPull":
+			add(90, prefix+" 镜像拉取失败", append([]string{c.Message}, eventMessages("Failed")...),
+				"确认镜像名称和 tag 是否正确，tag 是否存在",
+				"私有仓库需要在 Pod 或 ServiceAccount 上配置 imagePullSecrets",
+				"确认节点能够访问镜像仓库，检查网络和代理配置")
+		case "CreateContainerConfigError":
+			add(90, prefix+" 配置错误，无法创建容器", []string{c.Message},
+				"通常是引用的 ConfigMap、Secret 或其中的 key 不存在，检查 env/envFrom/volume 引用")
+		case "CreateContainerError", "RunContainerError":
+			add(80, prefix+" 容器运行时创建失败", []string{c.Message},
+				"检查 command/args、挂载路径和 securityContext 配置")
+		}
+
+		if c.LastReason == "OOMKilled" || (c.LastExitCode == 137 && c.MemoryLimit != "") {
+			limit := c.MemoryLimit
+			if limit == "" {
+				limit = "未设置"
+			}
+			add(90, prefix+" 内存不足被 OOMKilled", []string{fmt.Sprintf("上次退出原因 %s，退出码 %d，内存 limit %s", c.LastReason, c.LastExitCode, limit)},
+				"提高容器的 memory limit，或排查应用内存泄漏",
+				"JVM 等运行时需要让堆大小与容器 limit 匹配")
+			continue
+		}
+
+		if c.Reason == "CrashLoopBackOff" || (c.LastExitCode != 0 && c.RestartCount > 0) || (c.Init && c.LastExitCode != 0) {
+			evidence := []string{fmt.Sprintf("重启 %d 次，上次退出原因 %s，退出码 %d", c.RestartCount, c.LastReason, c.LastExitCode)}
+			if c.LastMessage != "" {
+				evidence = append(evidence, c.LastMessage)
+			}
+			if line := lastErrorLine(c.PreviousLogs); line != "" {
+				evidence = append(evidence, "日志: "+line)
+			}
+			title, hints := exitCodeHint(c.LastExitCode)
+			if c.Init {
+				hints = append(hints, "Init 容器失败会阻塞主容器启动，检查其依赖的服务或初始化脚本")
+			}
+			add(85, prefix+" "+title, evidence, hints...)
+		}
+	}
+
+	if d.Status == Pending || d.SchedulingMessage != "" {
+		msgs := eventMessages("FailedScheduling")
+		if d.SchedulingMessage != "" {
+			msgs = append([]string{d.SchedulingMessage}, msgs...)
+		}
+		if len(msgs) > 0 {
+			add(90, "Pod 无法调度", msgs, schedulingHints(strings.Join(msgs, "\n"))...)
+		}
+	}
+
+	if msgs := eventMessages("FailedMount", "FailedAttachVolume"); len(msgs) > 0 {
+		add(80, "存储卷挂载失败", msgs,
+			"检查 PVC 是否已绑定、引用的 ConfigMap/Secret 是否存在",
+			"RWO 卷被其他节点上的 Pod 占用时无法挂载，确认旧 Pod 已经删除")
+	}
+
+	if msgs := eventMessages("Unhealthy"); len(msgs) > 0 {
+		add(60, "健康检查失败", msgs,
+			"检查 liveness/readiness 探针的路径、端口和超时时间",
+			"应用启动较慢时配置 startupProbe 或增大 initialDelaySeconds")
+	}
+
+	if len(d.NodeConditions) > 0 {
+		var evidence []string
+		for _, cond := range d.NodeConditions {
+			evidence = append(evidence, fmt.Sprintf("%s=%s %s", cond.Type, cond.Status, cond.Message))
+		}
+		add(70, fmt.Sprintf("所在节点 %s 状态异常", d.NodeName), evidence,
+			"检查节点上 kubelet 和容器运行时状态，必要时 cordon 并驱逐节点上的 Pod")
+	}
+
+	sort.SliceStable(causes, func(i, j int) bool {
+		return causes[i].Score > causes[j].Score
+	})
+	return causes
+}
+
+// exitCodeHint 根据常见退出码给出原因说明
+func exitCodeHint(code int32) (string, []string) {
+	switch code {
+	case 0:
+		return "进程正常退出后被重启", []string{"容器主进程执行完就退出，Deployment 等控制器会不断重启它，确认启动命令是否为前台常驻进程"}
+	case 1:
+		return "应用启动失败 (exit 1)", []string{"查看上一次容器日志中的错误信息", "检查配置文件、环境变量和依赖服务的连接"}
+	case 126:
+		return "命令无法执行 (exit 126)", []string{"检查启动命令的执行权限"}
+	case 127:
+		return "命令不存在 (exit 127)", []string{"检查 command/args 和镜像中是否存在该可执行文件"}
+	case 137:
+		return "进程被 SIGKILL 终止 (exit 137)", []string{"可能是内存不足或 liveness 探针失败后被强制终止"}
+	case 139:
+		return "进程段错误 (exit 139)", []string{"应用发生段错误，检查镜像架构与节点是否匹配以及应用本身的问题"}
+	case 143:
+		return "进程被 SIGTERM 终止 (exit 143)", []string{"通常是 liveness 探针失败或 Pod 被删除，检查探针配置"}
+	}
+	return fmt.Sprintf("容器反复崩溃 (exit %d)", code), []string{"查看上一次容器日志中的错误信息"}
+}
+
+// schedulingHints 根据调度失败信息给出建议
+func schedulingHints(message string) []string {
+	var hints []string
+	if strings.Contains(message, "Insufficient cpu") || strings.Contains(message, "Insufficient memory") {
+		hints = append(hints, "集群资源不足，降低 requests 或扩容节点")
+	}
+	if strings.Contains(message, "node affinity") || strings.Contains(message, "node selector") {
+		hints = append(hints, "没有节点满足 nodeSelector/nodeAffinity，检查节点标签")
+	}
+	if strings.Contains(message, "untolerated taint") || strings.Contains(message, "taint") {
+		hints = append(hints, "节点存在 Pod 未容忍的污点，添加 tolerations 或去掉节点污点")
+	}
+	if strings.Contains(message, "PersistentVolumeClaim") || strings.Contains(message, "persistentvolumeclaim") {
+		hints = append(hints, "PVC 不存在或未绑定，检查 StorageClass 和 PV 供应情况")
+	}
+	if strings.Contains(message, "didn't match pod anti-affinity") || strings.Contains(message, "pod affinity") {
+		hints = append(hints, "Pod 亲和/反亲和规则无法满足，检查 podAntiAffinity 与副本数")
+	}
+	if strings.Contains(message, "Too many pods") {
+		hints = append(hints, "节点 Pod 数量达到上限")
+	}
+	if len(hints) == 0 {
+		hints = append(hints, "查看调度事件中的详细信息")
+	}
+	return hints
+}
+
+// lastErrorLine 返回日志中最后一行包含错误关键字的内容
+func lastErrorLine(logs string) string {
+	lines := strings.Split(strings.TrimSpace(logs), "\n")
+	for i := len(lines) - 1; i >= 0; i-- {
+		lower := strings.ToLower(lines[i])
+		for _, keyword := range []string{"error", "exception", "panic", "fatal", "failed"} {
+			if strings.Contains(lower, keyword) {
+				return strings.TrimSpace(lines[i])
+			}
+		}
+	}
+	return ""
+}
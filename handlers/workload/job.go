@@ -7,27 +7,41 @@ import (
 	"k8s-manage-api/handlers"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"strings"
+	"time"
 
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 type ListJobRequest struct {
+	handlers.ListQuery
 	JobName   string `json:"jobName"`
 	NameSpace string `json:"namespace"`
 }
 
 type ListJobResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Jobs []Job `json:"jobs"`
 }
 
 type Job struct {
-	Name       string            `json:"name"`
-	Namespace  string            `json:"namespace"`
-	Labels     map[string]string `json:"labels"`
-	Images     []string          `json:"images"`
-	Pods       string            `json:"pods"`
-	CreateTime string            `json:"createTime"`
+	Name          string            `json:"name"`
+	Namespace     string            `json:"namespace"`
+	Labels        map[string]string `json:"labels"`
+	Images        []string          `json:"images"`
+	Pods          string            `json:"pods"`
+	CreateTime    string            `json:"createTime"`
+	Status        string            `json:"status"`
+	Completions   string            `json:"completions"`
+	Active        int32             `json:"active"`
+	Succeeded     int32             `json:"succeeded"`
+	Failed        int32             `json:"failed"`
+	Duration      string            `json:"duration"`
+	FailureReason string            `json:"failureReason"`
 }
 
 func ListJob(w http.ResponseWriter, r *http.Request) {
@@ -46,7 +60,7 @@ func ListJob(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.JobName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.JobName)
 	}
@@ -56,7 +70,21 @@ func ListJob(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *batchv1.Job) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       JobStatus(svc),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Jobs = append(resp.Jobs, Job{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
@@ -68,8 +96,316 @@ func ListJob(w http.ResponseWriter, r *http.Request) {
 				}
 				return images
 			}(),
-			CreateTime: svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+			// 运行中的 Pod 数 / 已创建的 Pod 总数
+			Pods:          fmt.Sprintf("%d/%d", svc.Status.Active, svc.Status.Active+svc.Status.Succeeded+svc.Status.Failed),
+			CreateTime:    svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+			Status:        JobStatus(&svc),
+			Completions:   jobCompletions(&svc),
+			Active:        svc.Status.Active,
+			Succeeded:     svc.Status.Succeeded,
+			Failed:        svc.Status.Failed,
+			Duration:      jobDuration(&svc, time.Now()),
+			FailureReason: jobFailureReason(&svc),
 		})
 	}
 	return
 }
+
+// JobStatus 根据 Job 的 conditions 返回 Complete、Failed、Suspended 或 Running
+func JobStatus(job *batchv1.Job) string {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		switch cond.Type {
+		case batchv1.JobComplete:
+			return "Complete"
+		case batchv1.JobFailed:
+			return "Failed"
+		case batchv1.JobSuspended:
+			return "Suspended"
+		}
+	}
+	return "Running"
+}
+
+// jobCompletions 与 kubectl get job 的 COMPLETIONS 列一致
+func jobCompletions(job *batchv1.Job) string {
+	if job.Spec.Completions != nil {
+		return fmt.Sprintf("%d/%d", job.Status.Succeeded, *job.Spec.Completions)
+	}
+	// 未设置 completions 时，Job 由任意一个 Pod 成功即完成
+	parallelism := int32(0)
+	if job.Spec.Parallelism != nil {
+		parallelism = *job.Spec.Parallelism
+	}
+	if parallelism > 1 {
+		return fmt.Sprintf("%d/1 of %d", job.Status.Succeeded, parallelism)
+	}
+	return fmt.Sprintf("%d/1", job.Status.Succeeded)
+}
+
+// jobDuration 返回 Job 从开始到完成（或到当前时间）的耗时
+func jobDuration(job *batchv1.Job, now time.Time) string {
+	if job.Status.StartTime == nil {
+		return ""
+	}
+	end := now
+	if job.Status.CompletionTime != nil {
+		end = job.Status.CompletionTime.Time
+	} else {
+		for _, cond := range job.Status.Conditions {
+			if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+				end = cond.LastTransitionTime.Time
+			}
+		}
+	}
+	return end.Sub(job.Status.StartTime.Time).Round(time.Second).String()
+}
+
+// jobFailureReason 返回 Failed condition 的原因，例如 BackoffLimitExceeded、DeadlineExceeded
+func jobFailureReason(job *batchv1.Job) string {
+	for _, cond := range job.Status.Conditions {
+		if cond.Type == batchv1.JobFailed && cond.Status == corev1.ConditionTrue {
+			if cond.Message != "" {
+				return fmt.Sprintf("%s: %s", cond.Reason, cond.Message)
+			}
+			return cond.Reason
+		}
+	}
+	return ""
+}
+
+type RerunJobRequest struct {
+	JobName   string `json:"jobName"`
+	NameSpace string `json:"namespace"`
+}
+
+type RerunJobResponse struct {
+	handlers.ErrorResponse
+	JobName string `json:"jobName"`
+}
+
+// RerunJob 复制一个已结束的 Job 重新执行，新 Job 使用生成的名称
+func RerunJob(w http.ResponseWriter, r *http.Request) {
+	var resp RerunJobResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req RerunJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	job, err := clientset.BatchV1().Jobs(req.NameSpace).Get(context.TODO(), req.JobName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get job err: %s", err.Error())
+		return
+	}
+	if JobStatus(job) == "Running" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("job %s 仍在运行，不能重新执行", job.Name)
+		return
+	}
+
+	created, err := clientset.BatchV1().Jobs(req.NameSpace).Create(context.TODO(), cloneJob(job), metav1.CreateOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("创建 Job 失败: %v", err)
+		return
+	}
+	resp.JobName = created.Name
+}
+
+// jobControllerLabels 是 Job 控制器自动添加到 selector 和 Pod 模板上的标签，复制时需要去掉，否则新 Job 无法创建
+var jobControllerLabels = []string{
+	"controller-uid",
+	"job-name",
+	"batch.kubernetes.io/controller-uid",
+	"batch.kubernetes.io/job-name",
+}
+
+// cloneJob 去掉服务端生成的字段和控制器标签，生成一个可以重新创建的 Job
+func cloneJob(job *batchv1.Job) *batchv1.Job {
+	baseName := strings.TrimSuffix(job.Name, "-")
+	if idx := strings.Index(baseName, "-rerun-"); idx > 0 {
+		baseName = baseName[:idx]
+	}
+	if len(baseName) > 45 {
+		baseName = baseName[:45]
+	}
+
+	clone := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: baseName + "-rerun-",
+			Namespace:    job.Namespace,
+			Labels:       map[string]string{},
+			Annotations:  map[string]string{},
+		},
+		Spec: *job.Spec.DeepCopy(),
+	}
+	for k, v := range job.Labels {
+		clone.Labels[k] = v
+	}
+	for k, v := range job.Annotations {
+		clone.Annotations[k] = v
+	}
+	clone.Annotations["k8s-manage-api/rerun-of"] = job.Name
+
+	clone.Spec.Selector = nil
+	clone.Spec.ManualSelector = nil
+	// 非挂起状态下重新执行
+	clone.Spec.Suspend = nil
+	for _, label := range jobControllerLabels {
+		delete(clone.Labels, label)
+		delete(clone.Spec.Template.Labels, label)
+	}
+	return clone
+}
+
+type DeleteJobRequest struct {
+	JobName   string `json:"jobName"`
+	NameSpace string `json:"namespace"`
+	// Foreground、Background 或 Orphan，默认 Background
+	PropagationPolicy string `json:"propagationPolicy"`
+}
+
+type DeleteJobResponse struct {
+	handlers.ErrorResponse
+}
+
+func DeleteJob(w http.ResponseWriter, r *http.Request) {
+	var resp DeleteJobResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DeleteJobRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	policy, err := propagationPolicy(req.PropagationPolicy)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	clientset := k8s.GetClient()
+	err = clientset.BatchV1().Jobs(req.NameSpace).Delete(context.TODO(), req.JobName, metav1.DeleteOptions{
+		PropagationPolicy: &policy,
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("delete job err: %s", err.Error())
+		return
+	}
+}
+
+// propagationPolicy 解析删除策略，Job 的默认策略是 Orphan，这里默认改为 Background 以同时清理 Pod
+func propagationPolicy(policy string) (metav1.DeletionPropagation, error) {
+	switch metav1.DeletionPropagation(policy) {
+	case "":
+		return metav1.DeletePropagationBackground, nil
+	case metav1.DeletePropagationForeground, metav1.DeletePropagationBackground, metav1.DeletePropagationOrphan:
+		return metav1.DeletionPropagation(policy), nil
+	}
+	return "", fmt.Errorf("无效的 propagationPolicy: %s", policy)
+}
+
+type CleanupJobsRequest struct {
+	NameSpace string `json:"namespace"`
+	// 完成时间早于 ttlSeconds 秒之前的 Job 会被删除，必须大于 0
+	TTLSeconds    int64 `json:"ttlSeconds"`
+	IncludeFailed bool  `json:"includeFailed"`
+	DryRun        bool  `json:"dryRun"`
+}
+
+type CleanupJobsResponse struct {
+	handlers.ErrorResponse
+	Deleted []string          `json:"deleted"`
+	Failed  map[string]string `json:"failed"`
+}
+
+// CleanupJobs 批量删除命名空间下已完成且超过 TTL 的 Job
+func CleanupJobs(w http.ResponseWriter, r *http.Request) {
+	var resp CleanupJobsResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req CleanupJobsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.NameSpace == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "namespace 不能为空"
+		return
+	}
+	// 不填 ttlSeconds 时不能默认为 0，否则会删除所有已完成的 Job
+	if req.TTLSeconds <= 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "ttlSeconds 必须大于 0"
+		return
+	}
+
+	clientset := k8s.GetClient()
+	jobs, err := clientset.BatchV1().Jobs(req.NameSpace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取job列表失败: %v", err)
+		return
+	}
+
+	deadline := time.Now().Add(-time.Duration(req.TTLSeconds) * time.Second)
+	policy := metav1.DeletePropagationBackground
+	opt := metav1.DeleteOptions{PropagationPolicy: &policy}
+	if req.DryRun {
+		opt.DryRun = []string{metav1.DryRunAll}
+	}
+	resp.Failed = make(map[string]string)
+	for _, job := range jobs.Items {
+		finishedAt, ok := jobFinishedAt(&job, req.IncludeFailed)
+		if !ok || finishedAt.After(deadline) {
+			continue
+		}
+		err := clientset.BatchV1().Jobs(req.NameSpace).Delete(context.TODO(), job.Name, opt)
+		if err != nil && !errors.IsNotFound(err) {
+			resp.Failed[job.Name] = err.Error()
+			continue
+		}
+		resp.Deleted = append(resp.Deleted, job.Name)
+	}
+}
+
+// jobFinishedAt 返回 Job 进入终态的时间，未结束的 Job 返回 false
+func jobFinishedAt(job *batchv1.Job, includeFailed bool) (time.Time, bool) {
+	for _, cond := range job.Status.Conditions {
+		if cond.Status != corev1.ConditionTrue {
+			continue
+		}
+		if cond.Type == batchv1.JobComplete || (includeFailed && cond.Type == batchv1.JobFailed) {
+			if job.Status.CompletionTime != nil {
+				return job.Status.CompletionTime.Time, true
+			}
+			return cond.LastTransitionTime.Time, true
+		}
+	}
+	return time.Time{}, false
+}
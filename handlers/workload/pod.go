@@ -8,19 +8,25 @@ import (
 	"k8s-manage-api/k8s"
 	"net/http"
 	"strconv"
+	"strings"
 
 	v1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 )
 
 type ListPodRequest struct {
+	handlers.ListQuery
 	PodName   string `json:"podName"`
 	NameSpace string `json:"namespace"`
 }
 
 type ListPodResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Pods []Pod `json:"pods"`
 }
 
@@ -33,7 +39,6 @@ type Pod struct {
 	Mem        string            `json:"mem"`
 	Restart    int32             `json:"restart"`
 	CreateTime string            `json:"createTime"`
-	Yaml       string            `json:"yaml"`
 	Status     string            `json:"status"`
 	ContainersState string            `json:"containersState"`
 }
@@ -54,7 +59,7 @@ func ListPod(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.PodName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.PodName)
 	}
@@ -64,17 +69,25 @@ func ListPod(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, pod := range pods.Items {
-		pod.APIVersion = "v1"
-		pod.Kind = "Pod"
-		pod.ManagedFields = nil
-		// 转换为 YAML
-		yamlData,err:=json.Marshal(pod)
-		if err != nil {
-			resp.ErrorCode = "500"
-			resp.ErrorMessage = fmt.Sprintf("转换YAML失败: %v", err)
-			return
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, pods.Items, pods.ListMeta, func(pod *v1.Pod) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         pod.Name,
+			Namespace:    pod.Namespace,
+			CreationTime: pod.CreationTimestamp.Time,
+			Status:       Phase(pod),
+			Restarts: func() int {
+				_, _, restart := Statuses(pod.Status.ContainerStatuses)
+				return restart
+			}(),
 		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, pod := range items {
 		cr, _, restart := Statuses(pod.Status.ContainerStatuses)
 
 		resp.Pods = append(resp.Pods, Pod{
@@ -117,7 +130,6 @@ func ListPod(w http.ResponseWriter, r *http.Request) {
 				return 		strconv.Itoa(cr) + "/" + strconv.Itoa(len(pod.Spec.Containers))
 			}(),
 			Status: Phase(&pod),
-			Yaml: string(yamlData),
 		})
 	}
 	return
@@ -353,6 +365,12 @@ const (
 type DeletePodRequest struct {
 	PodName   string `json:"podName"`
 	NameSpace string `json:"namespace"`
+	// 优雅终止等待时间，不填时使用 Pod 的 terminationGracePeriodSeconds
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds"`
+	// 强制删除，等同于 kubectl delete --grace-period=0 --force，用于清理一直处于 Terminating 的 Pod
+	Force bool `json:"force"`
+	// Foreground、Background 或 Orphan
+	PropagationPolicy string `json:"propagationPolicy"`
 }
 
 type DeletePodResponse struct {
@@ -385,11 +403,235 @@ func DeletePod(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("get pod err: %s", err.Error())
 		return
 	}
-	err=clientset.CoreV1().Pods(req.NameSpace).Delete(context.TODO(), pod.Name, metav1.DeleteOptions{})
+	opt, err := podDeleteOptions(req.GracePeriodSeconds, req.Force, req.PropagationPolicy)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	err=clientset.CoreV1().Pods(req.NameSpace).Delete(context.TODO(), pod.Name, opt)
 	if err != nil {
 		resp.ErrorCode = "400"
 		resp.ErrorMessage = fmt.Sprintf("get pod err: %s", err.Error())
 		return
 	}
 	return
-}
\ No newline at end of file
+}
+
+// podDeleteOptions 根据优雅终止时间、强制删除和删除策略构建删除参数
+func podDeleteOptions(gracePeriodSeconds *int64, force bool, policy string) (metav1.DeleteOptions, error) {
+	opt := metav1.DeleteOptions{}
+	if gracePeriodSeconds != nil {
+		if *gracePeriodSeconds < 0 {
+			return opt, fmt.Errorf("gracePeriodSeconds 不能小于 0")
+		}
+		opt.GracePeriodSeconds = gracePeriodSeconds
+	}
+	if force {
+		zero := int64(0)
+		opt.GracePeriodSeconds = &zero
+	}
+	if policy != "" {
+		p, err := propagationPolicy(policy)
+		if err != nil {
+			return opt, err
+		}
+		opt.PropagationPolicy = &p
+	}
+	return opt, nil
+}
+
+type EvictPodRequest struct {
+	PodName            string `json:"podName"`
+	NameSpace          string `json:"namespace"`
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds"`
+}
+
+type EvictPodResponse struct {
+	handlers.ErrorResponse
+	Result PodActionResult `json:"result"`
+}
+
+type PodActionResult struct {
+	PodName   string `json:"podName"`
+	Namespace string `json:"namespace"`
+	Success   bool   `json:"success"`
+	// 驱逐被 PodDisruptionBudget 阻止
+	Blocked           bool                   `json:"blocked"`
+	Message           string                 `json:"message"`
+	DisruptionBudgets []DisruptionBudgetInfo `json:"disruptionBudgets"`
+}
+
+type DisruptionBudgetInfo struct {
+	Name               string `json:"name"`
+	MinAvailable       string `json:"minAvailable"`
+	MaxUnavailable     string `json:"maxUnavailable"`
+	CurrentHealthy     int32  `json:"currentHealthy"`
+	DesiredHealthy     int32  `json:"desiredHealthy"`
+	DisruptionsAllowed int32  `json:"disruptionsAllowed"`
+}
+
+// EvictPod 通过 Eviction 子资源驱逐 Pod，驱逐会遵守 PodDisruptionBudget
+func EvictPod(w http.ResponseWriter, r *http.Request) {
+	var resp EvictPodResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req EvictPodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	pod, err := clientset.CoreV1().Pods(req.NameSpace).Get(context.TODO(), req.PodName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get pod err: %s", err.Error())
+		return
+	}
+	resp.Result = evictPod(context.TODO(), pod, req.GracePeriodSeconds)
+	if !resp.Result.Success {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = resp.Result.Message
+		if resp.Result.Blocked {
+			resp.ErrorCode = "429"
+		}
+	}
+}
+
+func evictPod(ctx context.Context, pod *v1.Pod, gracePeriodSeconds *int64) PodActionResult {
+	result := PodActionResult{
+		PodName:   pod.Name,
+		Namespace: pod.Namespace,
+	}
+	eviction := &policyv1.Eviction{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      pod.Name,
+			Namespace: pod.Namespace,
+		},
+		DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: gracePeriodSeconds},
+	}
+	clientset := k8s.GetClient()
+	err := clientset.PolicyV1().Evictions(pod.Namespace).Evict(ctx, eviction)
+	if err == nil {
+		result.Success = true
+		return result
+	}
+
+	// 驱逐会违反 PodDisruptionBudget 时 API Server 返回 429
+	if errors.IsTooManyRequests(err) {
+		result.Blocked = true
+		result.DisruptionBudgets = podDisruptionBudgets(ctx, pod)
+		result.Message = fmt.Sprintf("驱逐被 PodDisruptionBudget 阻止: %s", err.Error())
+		return result
+	}
+	result.Message = fmt.Sprintf("evict pod err: %s", err.Error())
+	return result
+}
+
+// podDisruptionBudgets 返回选中该 Pod 的 PodDisruptionBudget
+func podDisruptionBudgets(ctx context.Context, pod *v1.Pod) []DisruptionBudgetInfo {
+	clientset := k8s.GetClient()
+	pdbs, err := clientset.PolicyV1().PodDisruptionBudgets(pod.Namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil
+	}
+	var result []DisruptionBudgetInfo
+	for _, pdb := range pdbs.Items {
+		selector, err := metav1.LabelSelectorAsSelector(pdb.Spec.Selector)
+		if err != nil || selector.Empty() || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		info := DisruptionBudgetInfo{
+			Name:               pdb.Name,
+			CurrentHealthy:     pdb.Status.CurrentHealthy,
+			DesiredHealthy:     pdb.Status.DesiredHealthy,
+			DisruptionsAllowed: pdb.Status.DisruptionsAllowed,
+		}
+		if pdb.Spec.MinAvailable != nil {
+			info.MinAvailable = pdb.Spec.MinAvailable.String()
+		}
+		if pdb.Spec.MaxUnavailable != nil {
+			info.MaxUnavailable = pdb.Spec.MaxUnavailable.String()
+		}
+		result = append(result, info)
+	}
+	return result
+}
+
+type BulkPodActionRequest struct {
+	NameSpace     string `json:"namespace"`
+	LabelSelector string `json:"labelSelector"`
+	// evict 或 delete
+	Action             string `json:"action"`
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds"`
+	Force              bool   `json:"force"`
+	PropagationPolicy  string `json:"propagationPolicy"`
+}
+
+type BulkPodActionResponse struct {
+	handlers.ErrorResponse
+	Results []PodActionResult `json:"results"`
+}
+
+// BulkPodAction 按标签选择器批量驱逐或删除 Pod，并返回每个 Pod 的结果
+func BulkPodAction(w http.ResponseWriter, r *http.Request) {
+	var resp BulkPodActionResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req BulkPodActionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	// 避免误操作整个命名空间
+	if strings.TrimSpace(req.LabelSelector) == "" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "labelSelector 不能为空"
+		return
+	}
+	if req.Action != "evict" && req.Action != "delete" {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("不支持的操作: %s", req.Action)
+		return
+	}
+	opt, err := podDeleteOptions(req.GracePeriodSeconds, req.Force, req.PropagationPolicy)
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+
+	clientset := k8s.GetClient()
+	pods, err := clientset.CoreV1().Pods(req.NameSpace).List(context.TODO(), metav1.ListOptions{
+		LabelSelector: req.LabelSelector,
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("获取pod列表失败: %v", err)
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		if req.Action == "evict" {
+			resp.Results = append(resp.Results, evictPod(context.TODO(), pod, req.GracePeriodSeconds))
+			continue
+		}
+		result := PodActionResult{PodName: pod.Name, Namespace: pod.Namespace, Success: true}
+		if err := clientset.CoreV1().Pods(pod.Namespace).Delete(context.TODO(), pod.Name, opt); err != nil {
+			result.Success = false
+			result.Message = fmt.Sprintf("delete pod err: %s", err.Error())
+		}
+		resp.Results = append(resp.Results, result)
+	}
+}
@@ -8,16 +8,18 @@ import (
 	"k8s-manage-api/k8s"
 	"net/http"
 
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	appsv1 "k8s.io/api/apps/v1"
 )
 
 type ListReplicasetRequest struct {
+	handlers.ListQuery
 	ReplicasetName string `json:"replicasetName"`
 	NameSpace      string `json:"namespace"`
 }
 
 type ListReplicasetResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Replicasets []Replicaset `json:"replicasets"`
 }
 
@@ -46,7 +48,7 @@ func ListReplicaset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.ReplicasetName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.ReplicasetName)
 	}
@@ -56,7 +58,21 @@ func ListReplicaset(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *appsv1.ReplicaSet) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       readyStatus(svc.Status.ReadyReplicas, svc.Status.Replicas),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Replicasets = append(resp.Replicasets, Replicaset{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
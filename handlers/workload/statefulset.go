@@ -7,27 +7,39 @@ import (
 	"k8s-manage-api/handlers"
 	"k8s-manage-api/k8s"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
 )
 
 type ListstatefulsetRequest struct {
+	handlers.ListQuery
 	StatefulsetName string `json:"statefulsetName"`
 	NameSpace       string `json:"namespace"`
 }
 
 type ListstatefulsetResponse struct {
 	handlers.ErrorResponse
+	handlers.ListMeta
 	Statefulsets []statefulset `json:"statefulsets"`
 }
 
 type statefulset struct {
-	Name       string            `json:"name"`
-	Namespace  string            `json:"namespace"`
-	Labels     map[string]string `json:"labels"`
-	Images     []string          `json:"images"`
-	Pods       string            `json:"pods"`
-	CreateTime string            `json:"createTime"`
+	Name                 string            `json:"name"`
+	Namespace            string            `json:"namespace"`
+	Labels               map[string]string `json:"labels"`
+	Images               []string          `json:"images"`
+	Pods                 string            `json:"pods"`
+	CreateTime           string            `json:"createTime"`
+	UpdateStrategy       string            `json:"updateStrategy"`
+	Partition            int32             `json:"partition"`
+	UpdatedReplicas      int32             `json:"updatedReplicas"`
+	VolumeClaimTemplates []string          `json:"volumeClaimTemplates"`
 }
 
 func Liststatefulset(w http.ResponseWriter, r *http.Request) {
@@ -46,7 +58,7 @@ func Liststatefulset(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	listOptions := metav1.ListOptions{}
+	listOptions := req.ListOptions()
 	if req.StatefulsetName != "" {
 		listOptions.FieldSelector = fmt.Sprintf("metadata.name=%s", req.StatefulsetName)
 	}
@@ -56,7 +68,21 @@ func Liststatefulset(w http.ResponseWriter, r *http.Request) {
 		resp.ErrorMessage = fmt.Sprintf("获取svc列表失败: %v", err)
 		return
 	}
-	for _, svc := range svcs.Items {
+	items, listMeta, err := handlers.ApplyListQuery(req.ListQuery, svcs.Items, svcs.ListMeta, func(svc *appsv1.StatefulSet) handlers.ListItem {
+		return handlers.ListItem{
+			Name:         svc.Name,
+			Namespace:    svc.Namespace,
+			CreationTime: svc.CreationTimestamp.Time,
+			Status:       readyStatus(svc.Status.ReadyReplicas, svc.Status.Replicas),
+		}
+	})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = err.Error()
+		return
+	}
+	resp.ListMeta = listMeta
+	for _, svc := range items {
 		resp.Statefulsets = append(resp.Statefulsets, statefulset{
 			Name:      svc.Name,
 			Namespace: svc.Namespace,
@@ -68,9 +94,397 @@ func Liststatefulset(w http.ResponseWriter, r *http.Request) {
 				}
 				return images
 			}(),
-			Pods:       fmt.Sprintf("%d/%d", svc.Status.ReadyReplicas, svc.Status.Replicas),
-			CreateTime: svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+			Pods:            fmt.Sprintf("%d/%d", svc.Status.ReadyReplicas, svc.Status.Replicas),
+			CreateTime:      svc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+			UpdateStrategy:  string(svc.Spec.UpdateStrategy.Type),
+			Partition:       statefulSetPartition(&svc),
+			UpdatedReplicas: svc.Status.UpdatedReplicas,
+			VolumeClaimTemplates: func() []string {
+				var names []string
+				for _, tpl := range svc.Spec.VolumeClaimTemplates {
+					names = append(names, tpl.Name)
+				}
+				return names
+			}(),
 		})
 	}
 	return
 }
+
+func statefulSetPartition(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.UpdateStrategy.RollingUpdate != nil && sts.Spec.UpdateStrategy.RollingUpdate.Partition != nil {
+		return *sts.Spec.UpdateStrategy.RollingUpdate.Partition
+	}
+	return 0
+}
+
+// statefulSetOrdinalStart 返回 spec.ordinals.start，未设置时序号从 0 开始
+func statefulSetOrdinalStart(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Ordinals != nil {
+		return sts.Spec.Ordinals.Start
+	}
+	return 0
+}
+
+func statefulSetReplicas(sts *appsv1.StatefulSet) int32 {
+	if sts.Spec.Replicas != nil {
+		return *sts.Spec.Replicas
+	}
+	return 1
+}
+
+// pvcOrdinal 按照 <模板名>-<StatefulSet 名>-<序号> 的命名规则解析 PVC 所属的模板和序号
+func pvcOrdinal(sts *appsv1.StatefulSet, pvcName string) (string, int32, bool) {
+	for _, tpl := range sts.Spec.VolumeClaimTemplates {
+		prefix := fmt.Sprintf("%s-%s-", tpl.Name, sts.Name)
+		if !strings.HasPrefix(pvcName, prefix) {
+			continue
+		}
+		ordinal, err := strconv.ParseInt(strings.TrimPrefix(pvcName, prefix), 10, 32)
+		if err != nil || ordinal < 0 {
+			continue
+		}
+		return tpl.Name, int32(ordinal), true
+	}
+	return "", 0, false
+}
+
+type SetStatefulSetPartitionRequest struct {
+	StatefulsetName string `json:"statefulsetName"`
+	NameSpace       string `json:"namespace"`
+	Partition       int32  `json:"partition"`
+}
+
+type SetStatefulSetPartitionResponse struct {
+	handlers.ErrorResponse
+}
+
+// SetStatefulSetPartition 设置滚动更新的 partition，只有序号大于等于 partition 的 Pod 会被更新，用于金丝雀发布
+func SetStatefulSetPartition(w http.ResponseWriter, r *http.Request) {
+	var resp SetStatefulSetPartitionResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req SetStatefulSetPartitionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.Partition < 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "partition 不能小于 0"
+		return
+	}
+
+	clientset := k8s.GetClient()
+	sts, err := clientset.AppsV1().StatefulSets(req.NameSpace).Get(context.TODO(), req.StatefulsetName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get statefulset err: %s", err.Error())
+		return
+	}
+	if sts.Spec.UpdateStrategy.Type == appsv1.OnDeleteStatefulSetStrategyType {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "updateStrategy 为 OnDelete 时不支持设置 partition"
+		return
+	}
+
+	patch := fmt.Sprintf(`{"spec":{"updateStrategy":{"type":"RollingUpdate","rollingUpdate":{"partition":%d}}}}`, req.Partition)
+	_, err = clientset.AppsV1().StatefulSets(req.NameSpace).Patch(context.TODO(), sts.Name, types.StrategicMergePatchType, []byte(patch), metav1.PatchOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("更新 partition 失败: %v", err)
+		return
+	}
+}
+
+type RestartStatefulSetPodRequest struct {
+	StatefulsetName string `json:"statefulsetName"`
+	NameSpace       string `json:"namespace"`
+	Ordinal         int32  `json:"ordinal"`
+}
+
+type RestartStatefulSetPodResponse struct {
+	handlers.ErrorResponse
+	PodName string `json:"podName"`
+}
+
+// RestartStatefulSetPod 删除指定序号的 Pod，由控制器以相同的名称和存储重新创建
+func RestartStatefulSetPod(w http.ResponseWriter, r *http.Request) {
+	var resp RestartStatefulSetPodResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req RestartStatefulSetPodRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	sts, err := clientset.AppsV1().StatefulSets(req.NameSpace).Get(context.TODO(), req.StatefulsetName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get statefulset err: %s", err.Error())
+		return
+	}
+	start := statefulSetOrdinalStart(sts)
+	if req.Ordinal < start || req.Ordinal >= start+statefulSetReplicas(sts) {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("序号 %d 超出范围 [%d, %d)", req.Ordinal, start, start+statefulSetReplicas(sts))
+		return
+	}
+
+	resp.PodName = fmt.Sprintf("%s-%d", sts.Name, req.Ordinal)
+	err = clientset.CoreV1().Pods(req.NameSpace).Delete(context.TODO(), resp.PodName, metav1.DeleteOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("delete pod err: %s", err.Error())
+		return
+	}
+}
+
+type ScaleStatefulSetRequest struct {
+	StatefulsetName string `json:"statefulsetName"`
+	NameSpace       string `json:"namespace"`
+	Replicas        int32  `json:"replicas"`
+	// 只返回缩容影响的 PVC，不真正修改副本数
+	DryRun bool `json:"dryRun"`
+}
+
+type ScaleStatefulSetResponse struct {
+	handlers.ErrorResponse
+	// 缩容后保留但不再被使用的 PVC
+	OrphanedPVCs []string `json:"orphanedPVCs"`
+	// persistentVolumeClaimRetentionPolicy.whenScaled 为 Delete 时会被删除的 PVC
+	DeletedPVCs []string `json:"deletedPVCs"`
+}
+
+// ScaleStatefulSet 修改副本数，并报告缩容时 volumeClaimTemplates 对应的哪些 PVC 会被遗留
+func ScaleStatefulSet(w http.ResponseWriter, r *http.Request) {
+	var resp ScaleStatefulSetResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ScaleStatefulSetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+	if req.Replicas < 0 {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = "replicas 不能小于 0"
+		return
+	}
+
+	clientset := k8s.GetClient()
+	sts, err := clientset.AppsV1().StatefulSets(req.NameSpace).Get(context.TODO(), req.StatefulsetName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get statefulset err: %s", err.Error())
+		return
+	}
+
+	// 缩容时被移除的序号对应的 PVC
+	start := statefulSetOrdinalStart(sts)
+	deleteWhenScaled := sts.Spec.PersistentVolumeClaimRetentionPolicy != nil &&
+		sts.Spec.PersistentVolumeClaimRetentionPolicy.WhenScaled == appsv1.DeletePersistentVolumeClaimRetentionPolicyType
+	for ordinal := start + req.Replicas; ordinal < start+statefulSetReplicas(sts); ordinal++ {
+		for _, tpl := range sts.Spec.VolumeClaimTemplates {
+			pvcName := fmt.Sprintf("%s-%s-%d", tpl.Name, sts.Name, ordinal)
+			_, err := clientset.CoreV1().PersistentVolumeClaims(req.NameSpace).Get(context.TODO(), pvcName, metav1.GetOptions{})
+			if err != nil {
+				if errors.IsNotFound(err) {
+					continue
+				}
+				resp.ErrorCode = "500"
+				resp.ErrorMessage = fmt.Sprintf("get pvc err: %s", err.Error())
+				return
+			}
+			if deleteWhenScaled {
+				resp.DeletedPVCs = append(resp.DeletedPVCs, pvcName)
+			} else {
+				resp.OrphanedPVCs = append(resp.OrphanedPVCs, pvcName)
+			}
+		}
+	}
+	if req.DryRun {
+		return
+	}
+
+	scale, err := clientset.AppsV1().StatefulSets(req.NameSpace).GetScale(context.TODO(), sts.Name, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("get scale err: %s", err.Error())
+		return
+	}
+	scale.Spec.Replicas = req.Replicas
+	_, err = clientset.AppsV1().StatefulSets(req.NameSpace).UpdateScale(context.TODO(), sts.Name, scale, metav1.UpdateOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("修改副本数失败: %v", err)
+		return
+	}
+}
+
+type ListStatefulSetPVCRequest struct {
+	StatefulsetName string `json:"statefulsetName"`
+	NameSpace       string `json:"namespace"`
+}
+
+type ListStatefulSetPVCResponse struct {
+	handlers.ErrorResponse
+	Ordinals []StatefulSetOrdinalPVC `json:"ordinals"`
+}
+
+type StatefulSetOrdinalPVC struct {
+	Ordinal int32 `json:"ordinal"`
+	// 序号超出当前副本范围，PVC 已不再被 Pod 使用
+	Orphaned bool             `json:"orphaned"`
+	PVCs     []StatefulSetPVC `json:"pvcs"`
+}
+
+type StatefulSetPVC struct {
+	Name         string `json:"name"`
+	Template     string `json:"template"`
+	Status       string `json:"status"`
+	Capacity     string `json:"capacity"`
+	StorageClass string `json:"storageClass"`
+	VolumeName   string `json:"volumeName"`
+	CreateTime   string `json:"createTime"`
+}
+
+// ListStatefulSetPVC 按序号列出 StatefulSet 的 PVC，包括缩容后遗留的 PVC
+func ListStatefulSetPVC(w http.ResponseWriter, r *http.Request) {
+	var resp ListStatefulSetPVCResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req ListStatefulSetPVCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	sts, err := clientset.AppsV1().StatefulSets(req.NameSpace).Get(context.TODO(), req.StatefulsetName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get statefulset err: %s", err.Error())
+		return
+	}
+	pvcs, err := clientset.CoreV1().PersistentVolumeClaims(req.NameSpace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil {
+		resp.ErrorCode = "500"
+		resp.ErrorMessage = fmt.Sprintf("获取pvc列表失败: %v", err)
+		return
+	}
+
+	start := statefulSetOrdinalStart(sts)
+	end := start + statefulSetReplicas(sts)
+	ordinals := make(map[int32]*StatefulSetOrdinalPVC)
+	for _, pvc := range pvcs.Items {
+		tpl, ordinal, ok := pvcOrdinal(sts, pvc.Name)
+		if !ok {
+			continue
+		}
+		if _, exists := ordinals[ordinal]; !exists {
+			ordinals[ordinal] = &StatefulSetOrdinalPVC{
+				Ordinal:  ordinal,
+				Orphaned: ordinal < start || ordinal >= end,
+			}
+		}
+		item := StatefulSetPVC{
+			Name:       pvc.Name,
+			Template:   tpl,
+			Status:     string(pvc.Status.Phase),
+			Capacity:   pvc.Status.Capacity.Storage().String(),
+			VolumeName: pvc.Spec.VolumeName,
+			CreateTime: pvc.CreationTimestamp.Format("2006-01-02 15:04:05"),
+		}
+		if pvc.Spec.StorageClassName != nil {
+			item.StorageClass = *pvc.Spec.StorageClassName
+		}
+		ordinals[ordinal].PVCs = append(ordinals[ordinal].PVCs, item)
+	}
+	for _, o := range ordinals {
+		resp.Ordinals = append(resp.Ordinals, *o)
+	}
+	sort.Slice(resp.Ordinals, func(i, j int) bool {
+		return resp.Ordinals[i].Ordinal < resp.Ordinals[j].Ordinal
+	})
+}
+
+type DeleteStatefulSetPVCRequest struct {
+	StatefulsetName string `json:"statefulsetName"`
+	NameSpace       string `json:"namespace"`
+	Ordinal         int32  `json:"ordinal"`
+	// 删除仍在副本范围内的 PVC 会导致 Pod 重建后丢失数据，需要显式确认
+	Force bool `json:"force"`
+}
+
+type DeleteStatefulSetPVCResponse struct {
+	handlers.ErrorResponse
+	Deleted []string `json:"deleted"`
+}
+
+// DeleteStatefulSetPVC 删除指定序号的所有 PVC
+func DeleteStatefulSetPVC(w http.ResponseWriter, r *http.Request) {
+	var resp DeleteStatefulSetPVCResponse
+	defer func() {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}()
+
+	// 解析请求参数
+	var req DeleteStatefulSetPVCRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("解析请求失败: %v", err)
+		return
+	}
+
+	clientset := k8s.GetClient()
+	sts, err := clientset.AppsV1().StatefulSets(req.NameSpace).Get(context.TODO(), req.StatefulsetName, metav1.GetOptions{})
+	if err != nil {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("get statefulset err: %s", err.Error())
+		return
+	}
+	start := statefulSetOrdinalStart(sts)
+	if req.Ordinal >= start && req.Ordinal < start+statefulSetReplicas(sts) && !req.Force {
+		resp.ErrorCode = "400"
+		resp.ErrorMessage = fmt.Sprintf("序号 %d 的 PVC 仍在使用中，如需删除请设置 force", req.Ordinal)
+		return
+	}
+
+	for _, tpl := range sts.Spec.VolumeClaimTemplates {
+		pvcName := fmt.Sprintf("%s-%s-%d", tpl.Name, sts.Name, req.Ordinal)
+		err := clientset.CoreV1().PersistentVolumeClaims(req.NameSpace).Delete(context.TODO(), pvcName, metav1.DeleteOptions{})
+		if err != nil {
+			if errors.IsNotFound(err) {
+				continue
+			}
+			resp.ErrorCode = "500"
+			resp.ErrorMessage = fmt.Sprintf("delete pvc %s err: %s", pvcName, err.Error())
+			return
+		}
+		resp.Deleted = append(resp.Deleted, pvcName)
+	}
+}
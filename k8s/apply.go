@@ -0,0 +1,294 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/dynamic"
+)
+
+// DefaultFieldManager 是没有指定 field manager 时服务端 apply 使用的名称
+const DefaultFieldManager = "k8s-manager-api"
+
+// ApplyOptions 中的 Strategy
+const (
+	// 服务端 apply，默认
+	ApplyStrategyServerSide = "apply"
+	// 对象不存在时创建，存在时 JSON merge patch，不能删除字段
+	ApplyStrategyMerge = "merge"
+)
+
+// ApplyResult 中的 Action
+const (
+	ActionCreated    = "Created"
+	ActionConfigured = "Configured"
+	ActionUnchanged  = "Unchanged"
+	// 对象已存在，按 SkipExisting 跳过
+	ActionSkipped = "Skipped"
+	// schema 校验不通过
+	ActionInvalid = "Invalid"
+	// 服务端 apply 字段冲突，可以用 force 接管
+	ActionConflict = "Conflict"
+	// 解码失败或被 API Server 拒绝
+	ActionFailed = "Failed"
+)
+
+type ApplyOptions struct {
+	FieldManager string
+	// 服务端 apply 冲突时强制接管字段
+	Force    bool
+	Strategy string
+	// apply set 的 ID，不为空时给对象加上 ApplySetLabel 标签
+	ApplySet string
+	// 删除属于 ApplySet 但不在本次 YAML 中的对象，只在 YAML 中出现的资源类型和命名空间中查找
+	Prune bool
+	// prune 时同时删除 YAML 中出现的集群级类型（例如 Namespace、ClusterRole）的对象，默认不删除
+	PruneClusterScoped bool
+	// 对象已存在时不修改
+	SkipExisting bool
+}
+
+func (o ApplyOptions) fieldManager() string {
+	if o.FieldManager == "" {
+		return DefaultFieldManager
+	}
+	return o.FieldManager
+}
+
+// ApplyResult 是单个文档的 apply 或预览结果
+type ApplyResult struct {
+	Index      int    `json:"index"`
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace"`
+	Name       string `json:"name"`
+	Action     string `json:"action"`
+	DryRun     bool   `json:"dryRun"`
+	// schema 校验错误，带字段路径
+	ValidationErrors []string `json:"validationErrors,omitempty"`
+	Error            string   `json:"error,omitempty"`
+	// 服务端 apply 冲突的字段和当前拥有者
+	Conflicts []FieldConflict `json:"conflicts,omitempty"`
+	// 线上对象和 dry-run 结果的 unified diff，只在预览和 dry-run 时返回
+	Diff string `json:"diff,omitempty"`
+}
+
+type FieldConflict struct {
+	Field      string `json:"field"`
+	Manager    string `json:"manager"`
+	APIVersion string `json:"apiVersion"`
+}
+
+// Failed 返回结果是否表示失败
+func (r ApplyResult) Failed() bool {
+	return r.Action == ActionInvalid || r.Action == ActionConflict || r.Action == ActionFailed
+}
+
+func newApplyResult(doc Document, dryRun bool) ApplyResult {
+	result := ApplyResult{Index: doc.Index, DryRun: dryRun}
+	if doc.Object != nil {
+		result.APIVersion = doc.Object.GetAPIVersion()
+		result.Kind = doc.Object.GetKind()
+		result.Namespace = doc.Object.GetNamespace()
+		result.Name = doc.Object.GetName()
+	}
+	return result
+}
+
+func (r *ApplyResult) fail(err error) {
+	r.Action = ActionFailed
+	r.Error = err.Error()
+	if conflicts := Conflicts(err); len(conflicts) > 0 {
+		r.Action = ActionConflict
+		r.Conflicts = conflicts
+	}
+}
+
+// ApplyAll 按依赖关系排序后逐个 apply 多文档 YAML 中的对象，单个对象失败时继续处理后面的对象。
+// 创建 CRD 后会等待它 Established 再处理后面的自定义资源。开启 prune 时，结果的最后是被删除的对象
+func (y *YamlOperation) ApplyAll(yamlData string, opt ApplyOptions) ([]ApplyResult, error) {
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	docs, err := y.Documents(yamlData)
+	if err != nil {
+		return nil, err
+	}
+	labelApplySet(docs, opt.ApplySet)
+	SortDocuments(docs, false)
+	results := make([]ApplyResult, 0, len(docs))
+	for i := range docs {
+		doc := &docs[i]
+		// 自定义资源的 CRD 可能是前面刚创建的
+		if doc.Object != nil && doc.Mapping == nil {
+			y.mapDocument(doc)
+		}
+		result := newApplyResult(*doc, y.dryRun)
+		if doc.Err != nil {
+			result.fail(doc.Err)
+			results = append(results, result)
+			continue
+		}
+		y.applyDocument(*doc, opt, y.dryRun, &result)
+		if !result.Failed() && !y.dryRun && isCRD(doc.Object) {
+			if err := y.waitCRDEstablished(doc.Object.GetName()); err != nil {
+				result.fail(err)
+			}
+		}
+		results = append(results, result)
+	}
+	if opt.Prune {
+		pruned, err := y.prune(docs, results, opt, y.dryRun)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, pruned...)
+	}
+	return results, nil
+}
+
+// Preview 不修改集群，对每个文档做 schema 校验和服务端 dry-run，返回与线上对象的差异。
+// 开启 prune 时，结果的最后是将被删除的对象
+func (y *YamlOperation) Preview(yamlData string, opt ApplyOptions) ([]ApplyResult, error) {
+	if err := opt.validate(); err != nil {
+		return nil, err
+	}
+	docs, err := y.Documents(yamlData)
+	if err != nil {
+		return nil, err
+	}
+	labelApplySet(docs, opt.ApplySet)
+	SortDocuments(docs, false)
+	results := make([]ApplyResult, 0, len(docs))
+	for _, doc := range docs {
+		result := newApplyResult(doc, true)
+		if doc.Err != nil {
+			result.fail(doc.Err)
+			results = append(results, result)
+			continue
+		}
+		// OpenAPI 文档获取失败时（例如聚合 API）跳过本地校验，仍然由 dry-run 校验
+		if errs, err := ValidateObject(doc.Object); err == nil && len(errs) > 0 {
+			result.Action = ActionInvalid
+			result.ValidationErrors = errs
+			results = append(results, result)
+			continue
+		}
+		y.applyDocument(doc, opt, true, &result)
+		results = append(results, result)
+	}
+	if opt.Prune {
+		pruned, err := y.prune(docs, results, opt, true)
+		if err != nil {
+			return results, err
+		}
+		results = append(results, pruned...)
+	}
+	return results, nil
+}
+
+// applyDocument 按 opt 的策略 apply 单个对象，返回 apply 前的线上对象（不存在时为 nil）和 apply 后的对象。
+// dry-run 时同时计算两者的差异
+func (y *YamlOperation) applyDocument(doc Document, opt ApplyOptions, dryRun bool, result *ApplyResult) (*unstructured.Unstructured, *unstructured.Unstructured) {
+	dri := y.ResourceInterface(doc)
+	live, err := dri.Get(y.ctx, doc.Object.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		live = nil
+	} else if err != nil {
+		result.fail(fmt.Errorf("获取线上对象失败: %v", err))
+		return nil, nil
+	}
+
+	if live != nil && opt.SkipExisting {
+		result.Action = ActionSkipped
+		return live, live
+	}
+
+	applied, err := y.applyObject(dri, doc.Object, live != nil, opt, dryRun)
+	if err != nil {
+		result.fail(err)
+		return live, nil
+	}
+	if dryRun {
+		if result.Diff, err = ObjectDiff(live, applied); err != nil {
+			result.fail(err)
+			return live, applied
+		}
+	}
+	switch {
+	case live == nil:
+		result.Action = ActionCreated
+	// dry-run 返回的对象保留线上的 resourceVersion，只能根据差异判断是否有变化
+	case dryRun && result.Diff == "":
+		result.Action = ActionUnchanged
+	case !dryRun && live.GetResourceVersion() == applied.GetResourceVersion():
+		result.Action = ActionUnchanged
+	default:
+		result.Action = ActionConfigured
+	}
+	return live, applied
+}
+
+func (y *YamlOperation) applyObject(dri dynamic.ResourceInterface, obj *unstructured.Unstructured, exists bool, opt ApplyOptions, dryRun bool) (*unstructured.Unstructured, error) {
+	var dryRunOpt []string
+	var fieldValidation string
+	if dryRun {
+		// dry-run 时拒绝未知字段和重复字段，让预览能发现拼错的字段
+		dryRunOpt = []string{metav1.DryRunAll}
+		fieldValidation = metav1.FieldValidationStrict
+	}
+	switch opt.Strategy {
+	case "", ApplyStrategyServerSide:
+		// metav1.ApplyOptions 不能设置 fieldValidation，直接发送 apply patch
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return dri.Patch(y.ctx, obj.GetName(), types.ApplyPatchType, data, metav1.PatchOptions{
+			FieldManager:    opt.fieldManager(),
+			Force:           &opt.Force,
+			DryRun:          dryRunOpt,
+			FieldValidation: fieldValidation,
+		})
+	case ApplyStrategyMerge:
+		if !exists {
+			return dri.Create(y.ctx, obj, metav1.CreateOptions{FieldManager: opt.fieldManager(), DryRun: dryRunOpt, FieldValidation: fieldValidation})
+		}
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return dri.Patch(y.ctx, obj.GetName(), types.MergePatchType, data, metav1.PatchOptions{FieldManager: opt.fieldManager(), DryRun: dryRunOpt, FieldValidation: fieldValidation})
+	}
+	return nil, fmt.Errorf("不支持的 apply 策略: %s", opt.Strategy)
+}
+
+// conflictMessage 匹配服务端 apply 冲突原因，例如
+// conflict with "kubectl-client-side-apply" using apps/v1
+var conflictMessage = regexp.MustCompile(`conflict with "([^"]*)"(?: with subresource "[^"]*")?(?: using (\S+))?`)
+
+// Conflicts 从服务端 apply 返回的 409 错误中解析冲突的字段和拥有者
+func Conflicts(err error) []FieldConflict {
+	status, ok := err.(errors.APIStatus)
+	if !ok || !errors.IsConflict(err) || status.Status().Details == nil {
+		return nil
+	}
+	var conflicts []FieldConflict
+	for _, cause := range status.Status().Details.Causes {
+		if cause.Type != metav1.CauseTypeFieldManagerConflict {
+			continue
+		}
+		conflict := FieldConflict{Field: strings.TrimPrefix(cause.Field, ".")}
+		if m := conflictMessage.FindStringSubmatch(cause.Message); m != nil {
+			conflict.Manager, conflict.APIVersion = m[1], m[2]
+		}
+		conflicts = append(conflicts, conflict)
+	}
+	return conflicts
+}
@@ -0,0 +1,193 @@
+package k8s
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+)
+
+// ApplySetLabel 标记对象属于哪个 apply set，同一个 apply set 再次 apply 时，
+// 带有这个标签但不在 YAML 中的对象会被删除
+const ApplySetLabel = "k8s-manage-api/apply-set"
+
+// ActionPruned 表示对象已从 YAML 中移除，被 prune 删除，dry-run 时表示将被删除
+const ActionPruned = "Pruned"
+
+// ActionPruneSkipped 表示某种资源无法 list（例如没有权限或聚合 API 不可用），没有对它做 prune
+const ActionPruneSkipped = "PruneSkipped"
+
+// objectKey 用于判断对象是否仍在 YAML 中
+type objectKey struct {
+	Group     string
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+func keyOf(obj *unstructured.Unstructured) objectKey {
+	gvk := obj.GroupVersionKind()
+	return objectKey{Group: gvk.Group, Kind: gvk.Kind, Namespace: obj.GetNamespace(), Name: obj.GetName()}
+}
+
+func (o ApplyOptions) validate() error {
+	if o.Prune && o.ApplySet == "" {
+		return fmt.Errorf("prune 需要指定 applySet")
+	}
+	if o.ApplySet != "" {
+		if errs := validation.IsValidLabelValue(o.ApplySet); len(errs) > 0 {
+			return fmt.Errorf("无效的 applySet %s: %s", o.ApplySet, strings.Join(errs, "; "))
+		}
+	}
+	return nil
+}
+
+// labelApplySet 给 YAML 中的对象加上 apply set 标签
+func labelApplySet(docs []Document, applySet string) {
+	if applySet == "" {
+		return
+	}
+	for _, doc := range docs {
+		if doc.Object == nil {
+			continue
+		}
+		labels := doc.Object.GetLabels()
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		labels[ApplySetLabel] = applySet
+		doc.Object.SetLabels(labels)
+	}
+}
+
+// prune 删除带有 apply set 标签但不在本次 YAML 中的对象，查找范围见 PruneCandidates。
+// 只要有一个对象 apply 失败就不做 prune，避免误删
+func (y *YamlOperation) prune(docs []Document, results []ApplyResult, opt ApplyOptions, dryRun bool) ([]ApplyResult, error) {
+	for i := range docs {
+		if results[i].Failed() {
+			return nil, fmt.Errorf("%s/%s apply 失败，跳过 prune", results[i].Kind, results[i].Name)
+		}
+	}
+
+	candidates, pruned, err := y.PruneCandidates(docs, opt)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pruned {
+		pruned[i].DryRun = dryRun
+	}
+	deleteOpt := metav1.DeleteOptions{}
+	if dryRun {
+		deleteOpt.DryRun = []string{metav1.DryRunAll}
+	}
+	policy := metav1.DeletePropagationBackground
+	deleteOpt.PropagationPolicy = &policy
+
+	for _, c := range candidates {
+		result := ApplyResult{
+			Index:      -1,
+			APIVersion: c.Object.GetAPIVersion(),
+			Kind:       c.Object.GetKind(),
+			Namespace:  c.Object.GetNamespace(),
+			Name:       c.Object.GetName(),
+			Action:     ActionPruned,
+			DryRun:     dryRun,
+		}
+		ri := y.dyn.Resource(c.GVR).Namespace(c.Object.GetNamespace())
+		if err := ri.Delete(y.ctx, c.Object.GetName(), deleteOpt); err != nil && !errors.IsNotFound(err) {
+			result.fail(fmt.Errorf("prune 失败: %v", err))
+		}
+		pruned = append(pruned, result)
+	}
+	return pruned, nil
+}
+
+// PruneCandidate 是 prune 将要删除的对象
+type PruneCandidate struct {
+	GVR    schema.GroupVersionResource
+	Object unstructured.Unstructured
+}
+
+// PruneCandidates 返回 prune 会删除的对象和无法查找的资源，docs 是本次 apply 的文档。
+// 只在 YAML 中出现的资源类型和命名空间中查找带有 apply set 标签、但不在 YAML 中的对象，
+// 避免 applySet 与其它团队重名时误删别的命名空间或类型的对象。集群级对象只有 PruneClusterScoped 时才查找，
+// 由控制器创建的子对象交给控制器处理
+func (y *YamlOperation) PruneCandidates(docs []Document, opt ApplyOptions) (candidates []PruneCandidate, skipped []ApplyResult, err error) {
+	keep := make(map[objectKey]bool)
+	seen := make(map[schema.GroupResource]bool)
+	var namespaced, clusterScoped []*meta.RESTMapping
+	namespaces := make(map[string]bool)
+	for _, doc := range docs {
+		if doc.Object == nil || doc.Mapping == nil {
+			continue
+		}
+		keep[keyOf(doc.Object)] = true
+		isNamespaced := doc.Mapping.Scope.Name() == meta.RESTScopeNameNamespace
+		if isNamespaced {
+			namespaces[doc.Object.GetNamespace()] = true
+		}
+		gr := doc.Mapping.Resource.GroupResource()
+		if seen[gr] {
+			continue
+		}
+		seen[gr] = true
+		if isNamespaced {
+			namespaced = append(namespaced, doc.Mapping)
+		} else if opt.PruneClusterScoped {
+			clusterScoped = append(clusterScoped, doc.Mapping)
+		}
+	}
+
+	selector := metav1.ListOptions{LabelSelector: ApplySetLabel + "=" + opt.ApplySet}
+	find := func(mapping *meta.RESTMapping, ri dynamic.ResourceInterface) {
+		gvr := mapping.Resource
+		objs, err := ri.List(y.ctx, selector)
+		if err != nil {
+			skipped = append(skipped, ApplyResult{
+				Index:      -1,
+				APIVersion: gvr.GroupVersion().String(),
+				Kind:       mapping.GroupVersionKind.Kind,
+				Action:     ActionPruneSkipped,
+				Error:      fmt.Sprintf("查找 %s 失败，跳过 prune: %v", gvr.String(), err),
+			})
+			return
+		}
+		for _, obj := range objs.Items {
+			if keep[keyOf(&obj)] || metav1.GetControllerOf(&obj) != nil {
+				continue
+			}
+			candidates = append(candidates, PruneCandidate{GVR: gvr, Object: obj})
+		}
+	}
+	for _, mapping := range namespaced {
+		for ns := range namespaces {
+			find(mapping, y.dyn.Resource(mapping.Resource).Namespace(ns))
+		}
+	}
+	for _, mapping := range clusterScoped {
+		find(mapping, y.dyn.Resource(mapping.Resource))
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		// 和删除 YAML 一样，按依赖关系的逆序删除
+		a, b := keyOf(&candidates[i].Object), keyOf(&candidates[j].Object)
+		ra, rb := documentRank(Document{Object: &candidates[i].Object}), documentRank(Document{Object: &candidates[j].Object})
+		if ra != rb {
+			return ra > rb
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+	return candidates, skipped, nil
+}
@@ -0,0 +1,53 @@
+package k8s
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 删除结果中的 Action
+const (
+	ActionDeleted = "Deleted"
+	// 对象已经不存在
+	ActionNotFound = "NotFound"
+)
+
+// DeleteAll 按依赖关系的逆序删除 YAML 中的对象，Namespace 和 CRD 最后删除。
+// 单个对象失败时继续删除后面的对象
+func (y *YamlOperation) DeleteAll(yamlData string, propagationPolicy metav1.DeletionPropagation) ([]ApplyResult, error) {
+	docs, err := y.Documents(yamlData)
+	if err != nil {
+		return nil, err
+	}
+	SortDocuments(docs, true)
+
+	opt := metav1.DeleteOptions{}
+	if propagationPolicy != "" {
+		opt.PropagationPolicy = &propagationPolicy
+	}
+	if y.dryRun {
+		opt.DryRun = []string{metav1.DryRunAll}
+	}
+	results := make([]ApplyResult, 0, len(docs))
+	for _, doc := range docs {
+		result := newApplyResult(doc, y.dryRun)
+		if doc.Err != nil {
+			result.fail(doc.Err)
+			results = append(results, result)
+			continue
+		}
+		err := y.ResourceInterface(doc).Delete(y.ctx, doc.Object.GetName(), opt)
+		switch {
+		case errors.IsNotFound(err):
+			result.Action = ActionNotFound
+		case err != nil:
+			result.fail(fmt.Errorf("删除失败: %v", err))
+		default:
+			result.Action = ActionDeleted
+		}
+		results = append(results, result)
+	}
+	return results, nil
+}
@@ -0,0 +1,42 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// ObjectDiff 返回两个对象去掉 managedFields、resourceVersion、generation 和 status 后的 unified diff，
+// 对象为 nil 时视为空
+func ObjectDiff(from, to *unstructured.Unstructured) (string, error) {
+	fromYaml, err := diffYaml(from)
+	if err != nil {
+		return "", err
+	}
+	toYaml, err := diffYaml(to)
+	if err != nil {
+		return "", err
+	}
+	return difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYaml),
+		B:        difflib.SplitLines(toYaml),
+		FromFile: "live",
+		ToFile:   "merged",
+		Context:  3,
+	})
+}
+
+func diffYaml(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	obj = obj.DeepCopy()
+	CleanObject(obj, CleanOptions{ManagedFields: true, ResourceVersion: true, Status: true})
+	unstructured.RemoveNestedField(obj.Object, "metadata", "generation")
+	data, err := ResourceToYAML(obj.Object)
+	if err != nil {
+		return "", fmt.Errorf("转换YAML失败: %v", err)
+	}
+	return data, nil
+}
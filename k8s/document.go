@@ -0,0 +1,91 @@
+package k8s
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	yamlutil "k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/dynamic"
+)
+
+// Document 是多文档 YAML 中的一个对象，解码或映射失败时 Err 不为空，不影响其它文档
+type Document struct {
+	// 从 0 开始，跳过空文档
+	Index   int
+	Object  *unstructured.Unstructured
+	Mapping *meta.RESTMapping
+	Err     error
+	// YAML 中原本写的命名空间，补上 default 之前的值
+	RawNamespace string
+}
+
+// Documents 逐个解码多文档 YAML 并获取资源映射，单个文档出错时继续处理后面的文档。
+// 命名空间级资源没有写 namespace 时使用 default
+func (y *YamlOperation) Documents(yamlData string) ([]Document, error) {
+	reader := yamlutil.NewYAMLReader(bufio.NewReader(strings.NewReader(yamlData)))
+	var docs []Document
+	for {
+		data, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("读取 YAML 失败: %v", err)
+		}
+		if len(bytes.TrimSpace(data)) == 0 {
+			continue
+		}
+
+		doc := Document{Index: len(docs)}
+		obj := &unstructured.Unstructured{}
+		jsonData, err := yamlutil.ToJSON(data)
+		if err == nil && string(jsonData) == "null" {
+			// 只有注释的文档
+			continue
+		}
+		if err == nil {
+			err = obj.UnmarshalJSON(jsonData)
+		}
+		if err != nil {
+			doc.Err = fmt.Errorf("解析资源失败: %v", err)
+			docs = append(docs, doc)
+			continue
+		}
+		doc.Object = obj
+		doc.RawNamespace = obj.GetNamespace()
+		y.mapDocument(&doc)
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// mapDocument 获取文档对象的资源映射。找不到时刷新一次 discovery 缓存，兼容刚创建的 CRD
+func (y *YamlOperation) mapDocument(doc *Document) {
+	gvk := doc.Object.GroupVersionKind()
+	mapping, err := y.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) {
+		y.mapper.Reset()
+		mapping, err = y.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		doc.Mapping, doc.Err = nil, fmt.Errorf("获取资源映射失败: %v", err)
+		return
+	}
+	doc.Mapping, doc.Err = mapping, nil
+	if mapping.Scope.Name() == meta.RESTScopeNameNamespace && doc.Object.GetNamespace() == "" {
+		doc.Object.SetNamespace("default")
+	}
+}
+
+// ResourceInterface 返回操作该文档对象的 dynamic 接口
+func (y *YamlOperation) ResourceInterface(doc Document) dynamic.ResourceInterface {
+	if doc.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+		return y.dyn.Resource(doc.Mapping.Resource).Namespace(doc.Object.GetNamespace())
+	}
+	return y.dyn.Resource(doc.Mapping.Resource)
+}
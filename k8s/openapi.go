@@ -0,0 +1,249 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/restmapper"
+	"k8s.io/kube-openapi/pkg/spec3"
+	"k8s.io/kube-openapi/pkg/validation/spec"
+)
+
+var (
+	restMapperOnce sync.Once
+	deferredMapper *restmapper.DeferredDiscoveryRESTMapper
+	shortcutMapper meta.RESTMapper
+
+	openAPIMu    sync.Mutex
+	openAPICache = make(map[string]*spec3.OpenAPI)
+)
+
+func initRESTMapper() {
+	restMapperOnce.Do(func() {
+		cached := memory.NewMemCacheClient(GetDiscoveryClient())
+		deferredMapper = restmapper.NewDeferredDiscoveryRESTMapper(cached)
+		shortcutMapper = restmapper.NewShortcutExpander(deferredMapper, cached, nil)
+	})
+}
+
+// KindFor 把 deploy、deployments、deployments.apps 这样的资源名解析为 GVK，apiVersion 可以为空。
+// 找不到时刷新一次 discovery 缓存，兼容新安装的 CRD
+func KindFor(resource, apiVersion string) (schema.GroupVersionKind, error) {
+	initRESTMapper()
+	gvr := schema.ParseGroupResource(resource).WithVersion("")
+	if apiVersion != "" {
+		gv, err := schema.ParseGroupVersion(apiVersion)
+		if err != nil {
+			return schema.GroupVersionKind{}, fmt.Errorf("无效的 apiVersion %s: %v", apiVersion, err)
+		}
+		gvr.Group, gvr.Version = gv.Group, gv.Version
+	}
+	gvk, err := shortcutMapper.KindFor(gvr)
+	if meta.IsNoMatchError(err) {
+		deferredMapper.Reset()
+		gvk, err = shortcutMapper.KindFor(gvr)
+	}
+	if err != nil {
+		return schema.GroupVersionKind{}, fmt.Errorf("无法识别资源 %s: %v", resource, err)
+	}
+	return gvk, nil
+}
+
+// OpenAPIDocument 获取某个 GroupVersion 的 /openapi/v3 文档。
+// 文档地址里带有内容的 hash，按地址缓存，CRD 变化后地址会跟着变化
+func OpenAPIDocument(gv schema.GroupVersion) (*spec3.OpenAPI, error) {
+	paths, err := GetDiscoveryClient().OpenAPIV3().Paths()
+	if err != nil {
+		return nil, fmt.Errorf("获取 OpenAPI 路径失败: %v", err)
+	}
+	path := "apis/" + gv.Group + "/" + gv.Version
+	if gv.Group == "" {
+		path = "api/" + gv.Version
+	}
+	client, ok := paths[path]
+	if !ok {
+		return nil, fmt.Errorf("%s 没有 OpenAPI v3 文档", gv.String())
+	}
+
+	url := client.ServerRelativeURL()
+	openAPIMu.Lock()
+	doc, ok := openAPICache[url]
+	openAPIMu.Unlock()
+	if ok {
+		return doc, nil
+	}
+
+	data, err := client.Schema("application/json")
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 的 OpenAPI 文档失败: %v", gv.String(), err)
+	}
+	doc = &spec3.OpenAPI{}
+	if err := json.Unmarshal(data, doc); err != nil {
+		return nil, fmt.Errorf("解析 %s 的 OpenAPI 文档失败: %v", gv.String(), err)
+	}
+	openAPIMu.Lock()
+	// 同一个 GroupVersion 只保留最新的文档
+	for k := range openAPICache {
+		if strings.SplitN(k, "?", 2)[0] == strings.SplitN(url, "?", 2)[0] {
+			delete(openAPICache, k)
+		}
+	}
+	openAPICache[url] = doc
+	openAPIMu.Unlock()
+	return doc, nil
+}
+
+// SchemaForKind 返回 GVK 对应的顶层 schema 和它所在的文档
+func SchemaForKind(gvk schema.GroupVersionKind) (*spec.Schema, *spec3.OpenAPI, error) {
+	doc, err := OpenAPIDocument(gvk.GroupVersion())
+	if err != nil {
+		return nil, nil, err
+	}
+	if doc.Components == nil {
+		return nil, nil, fmt.Errorf("%s 的 OpenAPI 文档没有 schema", gvk.GroupVersion().String())
+	}
+	// 按 key 排序，保证同一个 GVK 出现在多个 schema 时结果稳定
+	keys := make([]string, 0, len(doc.Components.Schemas))
+	for k := range doc.Components.Schemas {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		s := doc.Components.Schemas[k]
+		gvks, _ := s.Extensions["x-kubernetes-group-version-kind"].([]interface{})
+		for _, item := range gvks {
+			m, _ := item.(map[string]interface{})
+			if m["group"] == gvk.Group && m["version"] == gvk.Version && m["kind"] == gvk.Kind {
+				return s, doc, nil
+			}
+		}
+	}
+	return nil, nil, fmt.Errorf("找不到 %s 的 schema", gvk.String())
+}
+
+// ResolveSchema 展开 $ref，OpenAPI v3 中引用通常包在只有一项的 allOf 里
+func ResolveSchema(doc *spec3.OpenAPI, s *spec.Schema) *spec.Schema {
+	for i := 0; s != nil && i < 32; i++ {
+		ref := s.Ref.String()
+		if ref == "" && len(s.AllOf) == 1 {
+			ref = s.AllOf[0].Ref.String()
+		}
+		if ref == "" {
+			return s
+		}
+		target, ok := doc.Components.Schemas[SchemaRefName(ref)]
+		if !ok {
+			return s
+		}
+		s = target
+	}
+	return s
+}
+
+// SchemaRefName 返回引用在 components.schemas 中的 key
+func SchemaRefName(ref string) string {
+	return strings.TrimPrefix(ref, "#/components/schemas/")
+}
+
+// ValidateObject 按集群的 OpenAPI v3 schema 校验对象，返回带字段路径的错误，
+// 检查类型、必填字段、枚举值和未知字段
+func ValidateObject(obj *unstructured.Unstructured) ([]string, error) {
+	root, doc, err := SchemaForKind(obj.GroupVersionKind())
+	if err != nil {
+		return nil, err
+	}
+	var errs []string
+	validateValue(doc, root, obj.Object, "", &errs)
+	return errs, nil
+}
+
+func validateValue(doc *spec3.OpenAPI, s *spec.Schema, value interface{}, path string, errs *[]string) {
+	s = ResolveSchema(doc, s)
+	if value == nil {
+		return
+	}
+	field := path
+	if field == "" {
+		field = "<root>"
+	}
+	if v, _ := s.Extensions["x-kubernetes-int-or-string"].(bool); v {
+		switch value.(type) {
+		case string, int64, float64:
+		default:
+			*errs = append(*errs, fmt.Sprintf("%s: 类型应为 int 或 string", field))
+		}
+		return
+	}
+
+	if len(s.Enum) > 0 {
+		found := false
+		for _, e := range s.Enum {
+			if fmt.Sprint(e) == fmt.Sprint(value) {
+				found = true
+			}
+		}
+		if !found {
+			*errs = append(*errs, fmt.Sprintf("%s: 不支持的值 %v，可选值 %v", field, value, s.Enum))
+		}
+	}
+
+	expect := func(t string) bool {
+		if len(s.Type) == 0 || s.Type.Contains(t) || (t == "integer" && s.Type.Contains("number")) {
+			return true
+		}
+		*errs = append(*errs, fmt.Sprintf("%s: 类型应为 %s", field, s.Type[0]))
+		return false
+	}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		if !expect("object") {
+			return
+		}
+		for _, r := range s.Required {
+			if _, ok := v[r]; !ok {
+				*errs = append(*errs, fmt.Sprintf("%s: 缺少必填字段", joinPath(path, r)))
+			}
+		}
+		preserve, _ := s.Extensions["x-kubernetes-preserve-unknown-fields"].(bool)
+		for k, child := range v {
+			if prop, ok := s.Properties[k]; ok {
+				validateValue(doc, &prop, child, joinPath(path, k), errs)
+			} else if s.AdditionalProperties != nil && s.AdditionalProperties.Schema != nil {
+				validateValue(doc, s.AdditionalProperties.Schema, child, joinPath(path, k), errs)
+			} else if len(s.Properties) > 0 && !preserve && (s.AdditionalProperties == nil || !s.AdditionalProperties.Allows) {
+				*errs = append(*errs, fmt.Sprintf("%s: 未知字段", joinPath(path, k)))
+			}
+		}
+	case []interface{}:
+		if !expect("array") {
+			return
+		}
+		if s.Items != nil && s.Items.Schema != nil {
+			for i, child := range v {
+				validateValue(doc, s.Items.Schema, child, fmt.Sprintf("%s[%d]", path, i), errs)
+			}
+		}
+	case string:
+		expect("string")
+	case bool:
+		expect("boolean")
+	case int64:
+		expect("integer")
+	case float64:
+		expect("number")
+	}
+}
+
+func joinPath(path, field string) string {
+	if path == "" {
+		return field
+	}
+	return path + "." + field
+}
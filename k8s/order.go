@@ -0,0 +1,115 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// kindOrder 是创建时的先后顺序，删除时反过来。没有列出的类型（一般是自定义资源）排在最后
+var kindOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"PriorityClass",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"Secret",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"ClusterRole",
+	"ClusterRoleBinding",
+	"Role",
+	"RoleBinding",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"IngressClass",
+	"Ingress",
+	"APIService",
+	"MutatingWebhookConfiguration",
+	"ValidatingWebhookConfiguration",
+}
+
+var kindRank = func() map[string]int {
+	rank := make(map[string]int, len(kindOrder))
+	for i, kind := range kindOrder {
+		rank[kind] = i
+	}
+	return rank
+}()
+
+func documentRank(doc Document) int {
+	if doc.Object != nil {
+		if rank, ok := kindRank[doc.Object.GetKind()]; ok {
+			return rank
+		}
+	}
+	return len(kindOrder)
+}
+
+// SortDocuments 按依赖关系排序，Namespace、CRD 在创建时最先处理，reverse 为 true 时（删除）最后处理。
+// 同一类型保持 YAML 中的顺序
+func SortDocuments(docs []Document, reverse bool) {
+	sort.SliceStable(docs, func(i, j int) bool {
+		if reverse {
+			return documentRank(docs[i]) > documentRank(docs[j])
+		}
+		return documentRank(docs[i]) < documentRank(docs[j])
+	})
+}
+
+// CRDResource 是 CustomResourceDefinition 的 GVR
+var CRDResource = schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1", Resource: "customresourcedefinitions"}
+
+// crdEstablishTimeout 是等待 CRD Established 的最长时间
+const crdEstablishTimeout = 30 * time.Second
+
+func isCRD(obj *unstructured.Unstructured) bool {
+	return obj.GroupVersionKind().GroupKind() == schema.GroupKind{Group: "apiextensions.k8s.io", Kind: "CustomResourceDefinition"}
+}
+
+// waitCRDEstablished 等待 CRD 的 Established 条件为 True，之后才能创建对应的自定义资源
+func (y *YamlOperation) waitCRDEstablished(name string) error {
+	err := wait.PollUntilContextTimeout(y.ctx, time.Second, crdEstablishTimeout, true, func(ctx context.Context) (bool, error) {
+		crd, err := y.dyn.Resource(CRDResource).Get(ctx, name, metav1.GetOptions{})
+		if errors.IsNotFound(err) {
+			return false, nil
+		}
+		if err != nil {
+			return false, err
+		}
+		conditions, _, _ := unstructured.NestedSlice(crd.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, _ := c.(map[string]interface{})
+			if condition["type"] == "Established" && condition["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err != nil {
+		return fmt.Errorf("等待 CRD %s Established 失败: %v", name, err)
+	}
+	// 让后面的自定义资源能找到资源映射
+	y.mapper.Reset()
+	return nil
+}
@@ -0,0 +1,420 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	jsonpatch "gopkg.in/evanphx/json-patch.v4"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/strategicpatch"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// Overlay 类似 kustomize 的 overlay，在同一套基础 YAML 上生成不同环境的对象
+type Overlay struct {
+	NamePrefix string `json:"namePrefix"`
+	NameSuffix string `json:"nameSuffix"`
+	// 同时加到 Deployment 等工作负载的 selector、Pod 模板和 Service 的 selector 上，
+	// 已存在的工作负载修改 selector 会被 API Server 拒绝
+	CommonLabels      map[string]string `json:"commonLabels"`
+	CommonAnnotations map[string]string `json:"commonAnnotations"`
+	// 覆盖所有命名空间级对象的命名空间
+	Namespace string          `json:"namespace"`
+	Images    []ImageOverride `json:"images"`
+	// strategic merge patch，每个 patch 按 apiVersion、kind、metadata.name（以及可选的 namespace）匹配基础对象，
+	// 使用修改前的名称。patch 没有写 namespace 时匹配任意命名空间，基础对象没有写 namespace 时视为 default。
+	// 自定义资源按 JSON merge patch 处理
+	Patches []string `json:"patches"`
+}
+
+// ImageOverride 替换名称为 Name 的镜像，Name 不带 tag，例如 nginx 或 registry.example.com/app/web
+type ImageOverride struct {
+	Name    string `json:"name"`
+	NewName string `json:"newName"`
+	NewTag  string `json:"newTag"`
+	Digest  string `json:"digest"`
+}
+
+// 不加名称前后缀的类型，它们的名称通常被其它对象或集群约定引用
+var unprefixedKinds = map[string]bool{
+	"Namespace":                true,
+	"CustomResourceDefinition": true,
+	"APIService":               true,
+}
+
+// selectorKinds 的 spec.selector.matchLabels 和 spec.template.metadata.labels 会加上 commonLabels
+var selectorKinds = map[string]bool{
+	"Deployment":  true,
+	"ReplicaSet":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// BuildOverlay 在基础 YAML 上依次应用 patch、命名空间、名称前后缀、公共标签和注解、镜像替换，返回新的多文档 YAML
+func (y *YamlOperation) BuildOverlay(base string, overlay Overlay) (string, error) {
+	docs, err := y.Documents(base)
+	if err != nil {
+		return "", err
+	}
+	for _, doc := range docs {
+		if doc.Err != nil {
+			return "", fmt.Errorf("第 %d 个文档: %v", doc.Index+1, doc.Err)
+		}
+	}
+
+	for i, patch := range overlay.Patches {
+		if err := y.applyOverlayPatch(docs, patch); err != nil {
+			return "", fmt.Errorf("第 %d 个 patch: %v", i+1, err)
+		}
+	}
+
+	// 记录覆盖前的命名空间和 YAML 中的 ServiceAccount，只有指向它们的 subject 才跟着改命名空间
+	originalNamespaces := make([]string, len(docs))
+	serviceAccounts := make(map[string]bool)
+	for i, doc := range docs {
+		originalNamespaces[i] = doc.Object.GetNamespace()
+		if doc.Object.GetKind() == "ServiceAccount" {
+			serviceAccounts[doc.Object.GetNamespace()+"/"+doc.Object.GetName()] = true
+		}
+	}
+
+	// 记录改名前后的名称，用于更新对象之间的引用
+	renamed := make(map[string]map[string]string)
+	for _, doc := range docs {
+		obj := doc.Object
+		if overlay.Namespace != "" && doc.Mapping.Scope.Name() == meta.RESTScopeNameNamespace {
+			obj.SetNamespace(overlay.Namespace)
+		}
+		// 基础对象没有写命名空间时保持不写，由 apply 时的默认命名空间决定
+		if overlay.Namespace == "" && doc.RawNamespace == "" {
+			obj.SetNamespace("")
+		}
+		if (overlay.NamePrefix != "" || overlay.NameSuffix != "") && !unprefixedKinds[obj.GetKind()] {
+			name := overlay.NamePrefix + obj.GetName() + overlay.NameSuffix
+			if renamed[obj.GetKind()] == nil {
+				renamed[obj.GetKind()] = make(map[string]string)
+			}
+			renamed[obj.GetKind()][obj.GetName()] = name
+			obj.SetName(name)
+		}
+	}
+
+	var out []string
+	for i, doc := range docs {
+		obj := doc.Object
+		// 在改名之前处理，subject 中还是基础 YAML 中的名称
+		if overlay.Namespace != "" {
+			updateSubjectNamespaces(obj, overlay.Namespace, originalNamespaces[i], serviceAccounts)
+		}
+		if len(renamed) > 0 {
+			updateReferences(obj, renamed)
+		}
+		addCommonLabels(obj, overlay.CommonLabels)
+		obj.SetAnnotations(mergeStringMap(obj.GetAnnotations(), overlay.CommonAnnotations))
+		for _, image := range overlay.Images {
+			overrideImages(obj.Object, image)
+		}
+
+		data, err := ResourceToYAML(obj.Object)
+		if err != nil {
+			return "", fmt.Errorf("转换YAML失败: %v", err)
+		}
+		out = append(out, data)
+	}
+	return strings.Join(out, "---\n"), nil
+}
+
+// applyOverlayPatch 找到 patch 对应的基础对象并合并
+func (y *YamlOperation) applyOverlayPatch(docs []Document, patch string) error {
+	patchDocs, err := y.Documents(patch)
+	if err != nil {
+		return err
+	}
+	for _, p := range patchDocs {
+		if p.Object == nil {
+			return p.Err
+		}
+		target := -1
+		for i, doc := range docs {
+			obj := doc.Object
+			if obj.GroupVersionKind().GroupKind() == p.Object.GroupVersionKind().GroupKind() &&
+				obj.GetName() == p.Object.GetName() &&
+				(p.RawNamespace == "" || p.RawNamespace == obj.GetNamespace()) {
+				target = i
+				break
+			}
+		}
+		if target < 0 {
+			return fmt.Errorf("找不到 %s/%s", p.Object.GetKind(), p.Object.GetName())
+		}
+
+		original, err := json.Marshal(docs[target].Object.Object)
+		if err != nil {
+			return err
+		}
+		// patch 中的命名空间只用于匹配，不覆盖基础对象
+		unstructured.RemoveNestedField(p.Object.Object, "metadata", "namespace")
+		patchData, err := json.Marshal(p.Object.Object)
+		if err != nil {
+			return err
+		}
+		var merged []byte
+		if dataStruct, err := scheme.Scheme.New(p.Object.GroupVersionKind()); err == nil {
+			merged, err = strategicpatch.StrategicMergePatch(original, patchData, dataStruct)
+			if err != nil {
+				return fmt.Errorf("合并 %s/%s 失败: %v", p.Object.GetKind(), p.Object.GetName(), err)
+			}
+		} else {
+			merged, err = jsonpatch.MergePatch(original, patchData)
+			if err != nil {
+				return fmt.Errorf("合并 %s/%s 失败: %v", p.Object.GetKind(), p.Object.GetName(), err)
+			}
+		}
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(merged); err != nil {
+			return err
+		}
+		docs[target].Object = obj
+	}
+	return nil
+}
+
+func mergeStringMap(dst, src map[string]string) map[string]string {
+	if len(src) == 0 {
+		return dst
+	}
+	if dst == nil {
+		dst = make(map[string]string, len(src))
+	}
+	for k, v := range src {
+		dst[k] = v
+	}
+	return dst
+}
+
+func addCommonLabels(obj *unstructured.Unstructured, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	obj.SetLabels(mergeStringMap(obj.GetLabels(), labels))
+	setLabels := func(fields ...string) {
+		current, _, _ := unstructured.NestedStringMap(obj.Object, fields...)
+		unstructured.SetNestedStringMap(obj.Object, mergeStringMap(current, labels), fields...)
+	}
+	switch {
+	case selectorKinds[obj.GetKind()]:
+		setLabels("spec", "selector", "matchLabels")
+		setLabels("spec", "template", "metadata", "labels")
+	case obj.GetKind() == "Job":
+		// Job 的 selector 一般由 API Server 生成
+		setLabels("spec", "template", "metadata", "labels")
+	case obj.GetKind() == "CronJob":
+		setLabels("spec", "jobTemplate", "spec", "template", "metadata", "labels")
+	case obj.GetKind() == "Service":
+		if _, found, _ := unstructured.NestedFieldNoCopy(obj.Object, "spec", "selector"); found {
+			setLabels("spec", "selector")
+		}
+	}
+}
+
+// podSpecPath 返回工作负载中 Pod spec 的路径
+func podSpecPath(kind string) []string {
+	switch kind {
+	case "Pod":
+		return []string{"spec"}
+	case "Deployment", "ReplicaSet", "StatefulSet", "DaemonSet", "Job", "ReplicationController":
+		return []string{"spec", "template", "spec"}
+	case "CronJob":
+		return []string{"spec", "jobTemplate", "spec", "template", "spec"}
+	}
+	return nil
+}
+
+// updateReferences 把对其它对象的引用改成加了前后缀的名称，只处理 YAML 中存在的对象
+func updateReferences(obj *unstructured.Unstructured, renamed map[string]map[string]string) {
+	rename := func(kind string, m map[string]interface{}, field string) {
+		if name, ok := m[field].(string); ok {
+			if newName, ok := renamed[kind][name]; ok {
+				m[field] = newName
+			}
+		}
+	}
+	nested := func(m map[string]interface{}, fields ...string) map[string]interface{} {
+		v, _, _ := unstructured.NestedFieldNoCopy(m, fields...)
+		child, _ := v.(map[string]interface{})
+		return child
+	}
+	each := func(m map[string]interface{}, field string, fn func(map[string]interface{})) {
+		list, _ := m[field].([]interface{})
+		for _, item := range list {
+			if child, ok := item.(map[string]interface{}); ok {
+				fn(child)
+			}
+		}
+	}
+
+	if path := podSpecPath(obj.GetKind()); path != nil {
+		if spec := nested(obj.Object, path...); spec != nil {
+			rename("ServiceAccount", spec, "serviceAccountName")
+			each(spec, "imagePullSecrets", func(m map[string]interface{}) { rename("Secret", m, "name") })
+			each(spec, "volumes", func(v map[string]interface{}) {
+				if m := nested(v, "configMap"); m != nil {
+					rename("ConfigMap", m, "name")
+				}
+				if m := nested(v, "secret"); m != nil {
+					rename("Secret", m, "secretName")
+				}
+				if m := nested(v, "persistentVolumeClaim"); m != nil {
+					rename("PersistentVolumeClaim", m, "claimName")
+				}
+			})
+			for _, field := range []string{"containers", "initContainers"} {
+				each(spec, field, func(c map[string]interface{}) {
+					each(c, "envFrom", func(e map[string]interface{}) {
+						if m := nested(e, "configMapRef"); m != nil {
+							rename("ConfigMap", m, "name")
+						}
+						if m := nested(e, "secretRef"); m != nil {
+							rename("Secret", m, "name")
+						}
+					})
+					each(c, "env", func(e map[string]interface{}) {
+						if m := nested(e, "valueFrom", "configMapKeyRef"); m != nil {
+							rename("ConfigMap", m, "name")
+						}
+						if m := nested(e, "valueFrom", "secretKeyRef"); m != nil {
+							rename("Secret", m, "name")
+						}
+					})
+				})
+			}
+		}
+	}
+
+	switch obj.GetKind() {
+	case "StatefulSet":
+		if spec := nested(obj.Object, "spec"); spec != nil {
+			rename("Service", spec, "serviceName")
+		}
+	case "HorizontalPodAutoscaler":
+		if ref := nested(obj.Object, "spec", "scaleTargetRef"); ref != nil {
+			if kind, _ := ref["kind"].(string); kind != "" {
+				rename(kind, ref, "name")
+			}
+		}
+	case "Ingress":
+		if spec := nested(obj.Object, "spec"); spec != nil {
+			if m := nested(spec, "defaultBackend", "service"); m != nil {
+				rename("Service", m, "name")
+			}
+			each(spec, "tls", func(t map[string]interface{}) { rename("Secret", t, "secretName") })
+			each(spec, "rules", func(rule map[string]interface{}) {
+				if http := nested(rule, "http"); http != nil {
+					each(http, "paths", func(p map[string]interface{}) {
+						if m := nested(p, "backend", "service"); m != nil {
+							rename("Service", m, "name")
+						}
+					})
+				}
+			})
+		}
+	case "RoleBinding", "ClusterRoleBinding":
+		if ref := nested(obj.Object, "roleRef"); ref != nil {
+			if kind, _ := ref["kind"].(string); kind != "" {
+				rename(kind, ref, "name")
+			}
+		}
+		each(obj.Object, "subjects", func(s map[string]interface{}) {
+			if s["kind"] == "ServiceAccount" {
+				rename("ServiceAccount", s, "name")
+			}
+		})
+	}
+}
+
+// updateSubjectNamespaces 命名空间被覆盖后，绑定到同一命名空间或 YAML 中定义的 ServiceAccount 的 subject 也使用新的命名空间，
+// 指向其它命名空间（例如 kube-system）的 subject 保持不变。originalNamespace 是绑定对象覆盖前的命名空间，
+// serviceAccounts 的 key 是覆盖前的 namespace/name
+func updateSubjectNamespaces(obj *unstructured.Unstructured, namespace, originalNamespace string, serviceAccounts map[string]bool) {
+	if obj.GetKind() != "RoleBinding" && obj.GetKind() != "ClusterRoleBinding" {
+		return
+	}
+	subjects, _, _ := unstructured.NestedSlice(obj.Object, "subjects")
+	for _, item := range subjects {
+		s, ok := item.(map[string]interface{})
+		if !ok || s["kind"] != "ServiceAccount" {
+			continue
+		}
+		ns, _ := s["namespace"].(string)
+		name, _ := s["name"].(string)
+		if ns == "" {
+			continue
+		}
+		if (originalNamespace != "" && ns == originalNamespace) || serviceAccounts[ns+"/"+name] {
+			s["namespace"] = namespace
+		}
+	}
+	if subjects != nil {
+		unstructured.SetNestedSlice(obj.Object, subjects, "subjects")
+	}
+}
+
+// overrideImages 替换所有 containers、initContainers、ephemeralContainers 中匹配的镜像，
+// 同时适用于自定义资源中的 Pod 模板
+func overrideImages(value interface{}, image ImageOverride) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if key == "containers" || key == "initContainers" || key == "ephemeralContainers" {
+				if list, ok := child.([]interface{}); ok {
+					for _, item := range list {
+						if c, ok := item.(map[string]interface{}); ok {
+							if s, ok := c["image"].(string); ok {
+								c["image"] = replaceImage(s, image)
+							}
+						}
+					}
+				}
+			}
+			overrideImages(child, image)
+		}
+	case []interface{}:
+		for _, child := range v {
+			overrideImages(child, image)
+		}
+	}
+}
+
+func replaceImage(ref string, image ImageOverride) string {
+	name, tag, digest := splitImage(ref)
+	if name != image.Name {
+		return ref
+	}
+	if image.NewName != "" {
+		name = image.NewName
+	}
+	switch {
+	case image.Digest != "":
+		return name + "@" + image.Digest
+	case image.NewTag != "":
+		return name + ":" + image.NewTag
+	case digest != "":
+		return name + "@" + digest
+	case tag != "":
+		return name + ":" + tag
+	}
+	return name
+}
+
+// splitImage 拆分镜像地址，registry 的端口不会被当作 tag
+func splitImage(ref string) (name, tag, digest string) {
+	if i := strings.Index(ref, "@"); i >= 0 {
+		ref, digest = ref[:i], ref[i+1:]
+	}
+	if i := strings.LastIndex(ref, ":"); i > strings.LastIndex(ref, "/") {
+		ref, tag = ref[:i], ref[i+1:]
+	}
+	return ref, tag, digest
+}
@@ -29,6 +29,26 @@ var (
 )
 
 
+// CleanOptions 控制导出 YAML 时去掉哪些服务端填充的字段
+type CleanOptions struct {
+	ManagedFields   bool
+	ResourceVersion bool
+	Status          bool
+}
+
+// CleanObject 去掉对象中由服务端填充的字段，便于查看和重新 apply
+func CleanObject(obj *unstructured.Unstructured, opt CleanOptions) {
+	if opt.ManagedFields {
+		obj.SetManagedFields(nil)
+	}
+	if opt.ResourceVersion {
+		obj.SetResourceVersion("")
+	}
+	if opt.Status {
+		unstructured.RemoveNestedField(obj.Object, "status")
+	}
+}
+
 func ResourceToYAML(obj interface{}) (string, error) {
 	yamlBytes, err := sigyaml.Marshal(obj)
 	if err != nil {
@@ -200,7 +220,6 @@ func Apply(
 			}
 		}
 	}
-	return "", nil
 }
 
 
@@ -253,7 +272,7 @@ func NewYamlOperation(ctx context.Context, dryRun bool) (*YamlOperation, error)
 // Apply 应用 YAML 配置到集群
 func (y *YamlOperation) Apply(yamlData string) error {
     return y.processYaml(yamlData, func(dri dynamic.ResourceInterface, obj *unstructured.Unstructured) error {
-        opt := metav1.ApplyOptions{FieldManager: "k8s-manager-api"}
+        opt := metav1.ApplyOptions{FieldManager: DefaultFieldManager}
         if y.dryRun {
             opt.DryRun = []string{"All"}
         }
@@ -6,14 +6,21 @@ import (
 	"net/http"
 
 	"k8s-manage-api/handlers"
+	"k8s-manage-api/handlers/backup"
 	"k8s-manage-api/handlers/dashboard"
+	"k8s-manage-api/handlers/drift"
+	"k8s-manage-api/handlers/event"
+	"k8s-manage-api/handlers/history"
 	nodepool "k8s-manage-api/handlers/node_pool"
+	"k8s-manage-api/handlers/rbac/access"
 	"k8s-manage-api/handlers/rbac/clusterrole"
 	"k8s-manage-api/handlers/rbac/clusterrolebinding"
 	"k8s-manage-api/handlers/rbac/role"
 	"k8s-manage-api/handlers/rbac/rolebinding"
+	"k8s-manage-api/handlers/resource"
 	"k8s-manage-api/handlers/sa"
 	"k8s-manage-api/handlers/service"
+	"k8s-manage-api/handlers/template"
 	_ "k8s-manage-api/handlers/terminal"
 	"k8s-manage-api/handlers/workload"
 	"k8s-manage-api/middleware"
@@ -24,7 +31,7 @@ func enableCORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Remote-User")
 
 		if r.Method == "OPTIONS" {
 			w.WriteHeader(http.StatusOK)
@@ -70,14 +77,46 @@ func main() {
 			workload.ListPod(w, r)
 		case "/api/workload/pod/delete":
 			workload.DeletePod(w, r)
+		case "/api/workload/pod/evict":
+			workload.EvictPod(w, r)
+		case "/api/workload/pod/bulk":
+			workload.BulkPodAction(w, r)
+		case "/api/workload/pod/diagnose":
+			workload.DiagnosePod(w, r)
 		case "/api/workload/job/list":
 			workload.ListJob(w, r)
+		case "/api/workload/job/rerun":
+			workload.RerunJob(w, r)
+		case "/api/workload/job/delete":
+			workload.DeleteJob(w, r)
+		case "/api/workload/job/cleanup":
+			workload.CleanupJobs(w, r)
 		case "/api/workload/cronjob/list":
 			workload.ListCronJob(w, r)
+		case "/api/workload/cronjob/trigger":
+			workload.TriggerCronJob(w, r)
+		case "/api/workload/cronjob/suspend":
+			workload.SuspendCronJob(w, r)
+		case "/api/workload/cronjob/jobs":
+			workload.ListCronJobJobs(w, r)
+		case "/api/workload/cronjob/schedule":
+			workload.PreviewCronJobSchedule(w, r)
 		case "/api/workload/daemonset/list":
 			workload.ListDaemonset(w, r)
+		case "/api/workload/daemonset/coverage":
+			workload.GetDaemonSetCoverage(w, r)
 		case "/api/workload/statefulset/list":
 			workload.Liststatefulset(w, r)
+		case "/api/workload/statefulset/partition":
+			workload.SetStatefulSetPartition(w, r)
+		case "/api/workload/statefulset/restart-pod":
+			workload.RestartStatefulSetPod(w, r)
+		case "/api/workload/statefulset/scale":
+			workload.ScaleStatefulSet(w, r)
+		case "/api/workload/statefulset/pvc/list":
+			workload.ListStatefulSetPVC(w, r)
+		case "/api/workload/statefulset/pvc/delete":
+			workload.DeleteStatefulSetPVC(w, r)
 		case "/api/workload/pod/metrics":
 			workload.GetPodMetric(w, r)
 		case "/api/rbac/role/list":
@@ -90,10 +129,76 @@ func main() {
 			clusterrolebinding.ListClusterRoleBinding(w, r)	
 		case "/api/yaml/apply":
 			handlers.YamlApply(w, r)
+		case "/api/yaml/build":
+			handlers.YamlBuild(w, r)
+		case "/api/yaml/delete":
+			handlers.YamlDelete(w, r)
+		case "/api/template/list":
+			template.ListTemplate(w, r)
+		case "/api/template/get":
+			template.GetTemplate(w, r)
+		case "/api/template/save":
+			template.SaveTemplate(w, r)
+		case "/api/template/delete":
+			template.DeleteTemplate(w, r)
+		case "/api/template/render":
+			template.RenderTemplate(w, r)
+		case "/api/template/apply":
+			template.ApplyTemplate(w, r)
+		case "/api/backup/export":
+			backup.ExportNamespace(w, r)
+		case "/api/backup/import":
+			backup.ImportNamespace(w, r)
+		case "/api/drift":
+			drift.GetDrift(w, r)
+		case "/api/drift/sync":
+			drift.SyncDrift(w, r)
+		case "/api/drift/target/list":
+			drift.ListTarget(w, r)
+		case "/api/drift/target/save":
+			drift.SaveTarget(w, r)
+		case "/api/drift/target/delete":
+			drift.DeleteTarget(w, r)
+		case "/api/history/config":
+			history.GetConfig(w, r)
+		case "/api/history/config/save":
+			history.SaveConfig(w, r)
+		case "/api/history/snapshot":
+			history.TakeSnapshot(w, r)
+		case "/api/history/object":
+			history.GetObject(w, r)
+		case "/api/history/diff":
+			history.DiffNamespace(w, r)
+		case "/api/rbac/can-i":
+			access.CanI(w, r)
+		case "/api/rbac/risk":
+			access.RiskScan(w, r)
+		case "/api/rbac/risk/export":
+			access.RiskExport(w, r)
+		case "/api/rbac/who-can":
+			access.WhoCan(w, r)
+		case "/api/resource/yaml":
+			handlers.GetResourceYaml(w, r)
+		case "/api/resource/list":
+			resource.ListDynamicResource(w, r)
+		case "/api/resource/get":
+			resource.GetDynamicResource(w, r)
+		case "/api/resource/delete":
+			resource.DeleteDynamicResource(w, r)
+		case "/api/resource/patch":
+			resource.PatchDynamicResource(w, r)
+		case "/api/resource/explain":
+			resource.Explain(w, r)
 		case "/api/dashboard":
 			dashboard.GetClusterResourceStats(w, r)	
 		case "/api/node/metrics":
 			nodepool.GetNodeMetric(w, r)		
+		case "/api/event/list":
+			event.ListEvent(w, r)
+		case "/api/event/stream":
+			event.StreamEvent(w, r)
+		case "/api/event/warnings":
+			event.ListWarning(w, r)
 		default:
 			http.NotFound(w, r)
 		}
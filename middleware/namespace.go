@@ -10,8 +10,9 @@ import (
 
 func HandleAllNamespace(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		// 只处理包含请求体的请求
-		if r.Body != nil {
+		// 只处理包含请求体的请求。multipart（例如上传文件）交给处理函数自己限制大小和读取，
+		// 避免在这里把整个请求体读进内存
+		if r.Body != nil && !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/") {
 			// 读取请求体
 			body, err := io.ReadAll(r.Body)
 			if err != nil {
@@ -36,6 +37,9 @@ func HandleAllNamespace(next http.Handler) http.Handler {
 				// 替换请求体
 				r.Body = io.NopCloser(bytes.NewBuffer(newBody))
 				r.ContentLength = int64(len(newBody))
+			} else {
+				// 不是 JSON 时原样放回请求体
+				r.Body = io.NopCloser(bytes.NewBuffer(body))
 			}
 		}
 
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// 本地嵌入式存储，保存模板、快照等不适合放在集群里的数据。
+// 数据文件路径由环境变量 K8S_MANAGE_API_DB 指定，默认为 data/k8s-manage-api.db
+var (
+	dbOnce sync.Once
+	db     *bolt.DB
+	dbErr  error
+)
+
+func dbPath() string {
+	if path := os.Getenv("K8S_MANAGE_API_DB"); path != "" {
+		return path
+	}
+	return filepath.Join("data", "k8s-manage-api.db")
+}
+
+// DB 返回本地存储，第一次调用时打开
+func DB() (*bolt.DB, error) {
+	dbOnce.Do(func() {
+		path := dbPath()
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			dbErr = fmt.Errorf("创建数据目录失败: %v", err)
+			return
+		}
+		db, dbErr = bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+		if dbErr != nil {
+			dbErr = fmt.Errorf("打开本地存储 %s 失败: %v", path, dbErr)
+		}
+	})
+	return db, dbErr
+}
+
+// Get 读取 bucket 中 key 对应的 JSON 并解码到 v，不存在时返回 false
+func Get(bucket, key string, v interface{}) (bool, error) {
+	db, err := DB()
+	if err != nil {
+		return false, err
+	}
+	found := false
+	err = db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		data := b.Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, v)
+	})
+	return found, err
+}
+
+// Put 把 v 编码为 JSON 保存到 bucket 中
+func Put(bucket, key string, v interface{}) error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists([]byte(bucket))
+		if err != nil {
+			return err
+		}
+		return b.Put([]byte(key), data)
+	})
+}
+
+// Delete 删除 bucket 中的 key，不存在时不报错
+func Delete(bucket, key string) error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	return db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.Delete([]byte(key))
+	})
+}
+
+// List 按 key 的顺序遍历 bucket
+func List(bucket string, fn func(key string, data []byte) error) error {
+	db, err := DB()
+	if err != nil {
+		return err
+	}
+	return db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte(bucket))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			return fn(string(k), v)
+		})
+	})
+}